@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/output"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/spf13/cobra"
+)
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and clear cached downstream kubeconfigs",
+	}
+
+	cmd.AddCommand(newCacheListCmd())
+	cmd.AddCommand(newCacheShowCmd())
+	cmd.AddCommand(newCachePurgeCmd())
+	cmd.AddCommand(newCacheKeygenCmd())
+
+	return cmd
+}
+
+func newCacheListCmd() *cobra.Command {
+	var outputMode string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cached kubeconfigs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := output.ParseMode(outputMode)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			cache, err := rancher.OpenCache()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			entries, err := cache.ListKubeconfigs()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			if err := output.RenderCacheEntries(entries, cacheTTL, mode); err != nil {
+				return exit.New(1, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
+
+	return cmd
+}
+
+func newCacheShowCmd() *cobra.Command {
+	var (
+		outputMode     string
+		rancherURL     string
+		showKubeconfig bool
+	)
+	cmd := &cobra.Command{
+		Use:   "show <cluster>",
+		Short: "Show a cached kubeconfig by cluster name or ID",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := output.ParseMode(outputMode)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			cache, err := rancher.OpenCache()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			entries, err := cache.ListKubeconfigs()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			matches := selectCacheEntries(entries, args, rancherURL)
+			switch len(matches) {
+			case 0:
+				return exit.New(1, fmt.Errorf("no cached kubeconfig for cluster %q", args[0]))
+			case 1:
+			default:
+				return exit.New(1, fmt.Errorf("cluster %q is cached for %d Rancher servers; use --rancher-url", args[0], len(matches)))
+			}
+			if showKubeconfig {
+				fmt.Fprint(os.Stdout, matches[0].Kubeconfig)
+				return nil
+			}
+			if err := output.RenderCacheEntry(matches[0], cacheTTL, mode); err != nil {
+				return exit.New(1, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
+	cmd.Flags().StringVar(&rancherURL, "rancher-url", "", "only consider entries for this Rancher server")
+	cmd.Flags().BoolVar(&showKubeconfig, "kubeconfig", false, "print the cached kubeconfig itself")
+
+	return cmd
+}
+
+func newCachePurgeCmd() *cobra.Command {
+	var (
+		rancherURL  string
+		expiredOnly bool
+	)
+	cmd := &cobra.Command{
+		Use:   "purge [cluster...]",
+		Short: "Remove cached kubeconfigs (all, by cluster name/ID, or only expired ones); a full purge also drops cached listings",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var remove func(rancher.KubeconfigCacheEntry) bool
+			if len(args) > 0 || rancherURL != "" || expiredOnly {
+				now := time.Now()
+				remove = func(entry rancher.KubeconfigCacheEntry) bool {
+					if expiredOnly && !entry.Expired(cacheTTL, now) {
+						return false
+					}
+					return len(selectCacheEntries([]rancher.KubeconfigCacheEntry{entry}, args, rancherURL)) == 1
+				}
+			}
+			cache, err := rancher.OpenCache()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			removed, err := cache.PurgeKubeconfigs(remove)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			fmt.Fprintf(os.Stdout, "Removed %d cached kubeconfigs from %s\n", removed, cache.Dir())
+			if remove == nil {
+				results, err := cache.PurgeResults()
+				if err != nil {
+					return exit.New(1, err)
+				}
+				fmt.Fprintf(os.Stdout, "Removed %d cached listings\n", results)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&rancherURL, "rancher-url", "", "only purge entries for this Rancher server")
+	cmd.Flags().BoolVar(&expiredOnly, "expired", false, "only purge entries past --cache-ttl or whose token expired")
+
+	return cmd
+}
+
+func newCacheKeygenCmd() *cobra.Command {
+	var keyFile string
+	cmd := &cobra.Command{
+		Use:   "keygen",
+		Short: "Create a key so cached credentials are encrypted at rest",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path := firstNonEmpty(keyFile, os.Getenv(rancher.CacheKeyFileEnv))
+			if path == "" {
+				var err error
+				path, err = rancher.DefaultCacheKeyFile()
+				if err != nil {
+					return exit.New(1, err)
+				}
+			}
+			if err := rancher.GenerateCacheKey(path); err != nil {
+				return exit.New(1, fmt.Errorf("failed to create cache key: %w", err))
+			}
+			fmt.Fprintf(os.Stdout, "Cache key written to %s; new cache entries are encrypted\n", path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&keyFile, "key-file", "", "where to write the key (defaults to "+rancher.CacheKeyFileEnv+" or the user config dir)")
+
+	return cmd
+}
+
+// openCache returns the shared cache, or nil when --no-cache is set.
+func openCache() (*rancher.Cache, error) {
+	if noCache {
+		return nil, nil
+	}
+	cache, err := rancher.OpenCache()
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "cache dir=%s encrypted=%t\n", cache.Dir(), cache.Encrypted())
+	}
+	return cache, nil
+}
+
+// selectCacheEntries keeps entries whose cluster ID or name is in clusters
+// (all when empty) and, when set, whose Rancher URL matches rancherURL.
+func selectCacheEntries(entries []rancher.KubeconfigCacheEntry, clusters []string, rancherURL string) []rancher.KubeconfigCacheEntry {
+	base := ""
+	if rancherURL != "" {
+		if parsed, _, err := rancher.ParseRancherURL(rancherURL); err == nil {
+			base = parsed.String()
+		} else {
+			base = rancherURL
+		}
+	}
+	var selected []rancher.KubeconfigCacheEntry
+	for _, entry := range entries {
+		if entry.Err != nil {
+			continue
+		}
+		if base != "" && entry.RancherURL != base {
+			continue
+		}
+		if len(clusters) > 0 && !slices.Contains(clusters, entry.ClusterID) && (entry.ClusterName == "" || !slices.Contains(clusters, entry.ClusterName)) {
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	return selected
+}
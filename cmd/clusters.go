@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+type clusterNodesResult struct {
+	cluster rancher.Cluster
+	nodes   []types.K8sNode
+	err     error
+}
+
+func fetchClusterNodes(ctx context.Context, session *rancher.Session, cache *rancher.Cache, cluster rancher.Cluster, query nodeQuery) ([]types.K8sNode, error) {
+	name := session.ResultCacheName("nodes", append([]string{cluster.ID}, query.cacheKey()...)...)
+	nodes, err := cachedList(cache, "nodes cluster="+clusterDisplayName(cluster), name, func() ([]types.K8sNode, error) {
+		return listRancherClusterNodes(ctx, session, cache, cluster, query)
+	})
+	if err != nil {
+		return nil, err
+	}
+	tagNodesWithCluster(nodes, clusterDisplayName(cluster))
+	return nodes, nil
+}
+
+func listRancherClusterNodes(ctx context.Context, session *rancher.Session, cache *rancher.Cache, cluster rancher.Cluster, query nodeQuery) ([]types.K8sNode, error) {
+	if query.cachedOnly {
+		kubeConfig, err := cachedDownstreamKubeconfig(ctx, session, cache, cluster)
+		if err == nil && kubeConfig == nil {
+			err = exit.New(1, fmt.Errorf("no cached kubeconfig for cluster %s; run match once", clusterDisplayName(cluster)))
+		}
+		if err != nil {
+			return nil, err
+		}
+		return query.list(ctx, kubeConfig)
+	}
+	kubeconfigBytes, cached, err := clusterKubeconfig(ctx, session, cache, cluster)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := listClusterNodes(ctx, kubeconfigBytes, cluster, query)
+	var apiErr *k8s.APIError
+	if err != nil && cached && errors.As(err, &apiErr) && apiErr.Kind == k8s.ErrAuthFailed {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "cached kubeconfig rejected cluster=%s; fetching a new one\n", clusterDisplayName(cluster))
+		}
+		if err := cache.DeleteKubeconfig(session.BaseURL(), cluster.ID); err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "kubeconfig cache delete failed: %v\n", err)
+		}
+		kubeconfigBytes, err = generateClusterKubeconfig(ctx, session, cache, cluster)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = listClusterNodes(ctx, kubeconfigBytes, cluster, query)
+	}
+	return nodes, err
+}
+
+// cachedDownstreamKubeconfig returns the cached kubeconfig for cluster, or nil
+// when there is none.
+func cachedDownstreamKubeconfig(ctx context.Context, session *rancher.Session, cache *rancher.Cache, cluster rancher.Cluster) (clientcmd.ClientConfig, error) {
+	if cache == nil {
+		return nil, nil
+	}
+	entry, hit, err := cache.LoadKubeconfig(session.BaseURL(), cluster.ID, cacheTTL)
+	if err != nil || !hit {
+		return nil, err
+	}
+	kubeConfig, _, err := downstreamClientConfig(ctx, []byte(entry.Kubeconfig), cluster)
+	return kubeConfig, err
+}
+
+// clusterKubeconfig returns the kubeconfig for cluster and whether it came
+// from the cache; a nil cache always asks Rancher.
+func clusterKubeconfig(ctx context.Context, session *rancher.Session, cache *rancher.Cache, cluster rancher.Cluster) ([]byte, bool, error) {
+	if cache != nil {
+		entry, hit, err := cache.LoadKubeconfig(session.BaseURL(), cluster.ID, cacheTTL)
+		if err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "kubeconfig cache read failed: %v\n", err)
+		}
+		if hit {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "using cached kubeconfig cluster=%s age=%s\n", clusterDisplayName(cluster), entry.Age(time.Now()).Truncate(time.Second))
+			}
+			return []byte(entry.Kubeconfig), true, nil
+		}
+	}
+	kubeconfigBytes, err := generateClusterKubeconfig(ctx, session, cache, cluster)
+	return kubeconfigBytes, false, err
+}
+
+func generateClusterKubeconfig(ctx context.Context, session *rancher.Session, cache *rancher.Cache, cluster rancher.Cluster) ([]byte, error) {
+	kubeconfigBytes, err := session.GenerateKubeconfig(ctx, cluster.ID)
+	if err != nil {
+		return nil, exit.New(2, err)
+	}
+	if cache == nil {
+		return kubeconfigBytes, nil
+	}
+	entry := rancher.KubeconfigCacheEntry{
+		RancherURL:     session.BaseURL(),
+		ClusterID:      cluster.ID,
+		ClusterName:    cluster.Name,
+		TokenExpiresAt: kubeconfigTokenExpiry(ctx, session, kubeconfigBytes),
+		Kubeconfig:     string(kubeconfigBytes),
+	}
+	if err := cache.SaveKubeconfig(entry); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "kubeconfig cache write failed: %v\n", err)
+	}
+	return kubeconfigBytes, nil
+}
+
+// kubeconfigTokenExpiry looks up the expiry of the Rancher token embedded in a
+// generated kubeconfig; the zero time means unknown.
+func kubeconfigTokenExpiry(ctx context.Context, session *rancher.Session, kubeconfigBytes []byte) time.Time {
+	clientConfig, info, err := k8s.ResolveKubeconfigFromBytes(kubeconfigBytes, "rancher", nil, "", rest.ImpersonationConfig{})
+	if err != nil {
+		return time.Time{}
+	}
+	_, token, err := k8s.ExtractServerAndToken(clientConfig, info.Context, k8s.ExecPolicy{})
+	if err != nil {
+		return time.Time{}
+	}
+	name, _, ok := strings.Cut(token, ":")
+	if !ok {
+		return time.Time{}
+	}
+	details, err := session.GetToken(ctx, name)
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "kubeconfig token expiry unknown: %v\n", err)
+		}
+		return time.Time{}
+	}
+	return details.ExpiresAt
+}
+
+func listClusterNodes(ctx context.Context, kubeconfigBytes []byte, cluster rancher.Cluster, query nodeQuery) ([]types.K8sNode, error) {
+	kubeConfig, info, err := downstreamClientConfig(ctx, kubeconfigBytes, cluster)
+	if err != nil {
+		return nil, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "%s cluster=%s\n", k8s.DescribeKubeconfig(info), cluster.Name)
+	}
+	return query.list(ctx, kubeConfig)
+}
+
+// endpointProbeTimeout bounds the reachability check of each direct endpoint
+// before --endpoint auto moves on to the next context.
+const endpointProbeTimeout = 5 * time.Second
+
+// downstreamClientConfig picks the context of a Rancher-generated kubeconfig
+// according to --endpoint; an explicit --context always wins. Every
+// candidate but the last is probed, so auto falls back to the proxy.
+func downstreamClientConfig(ctx context.Context, kubeconfigBytes []byte, cluster rancher.Cluster) (clientcmd.ClientConfig, k8s.KubeconfigInfo, error) {
+	paths := []string{"cluster:" + cluster.ID}
+	candidates := []string{kubeContext}
+	if kubeContext == "" {
+		raw, err := clientcmd.Load(kubeconfigBytes)
+		if err != nil {
+			return nil, k8s.KubeconfigInfo{}, exit.New(1, &k8s.ConfigError{Kind: k8s.ErrKubeconfigInvalid, Paths: paths, Err: err})
+		}
+		candidates, err = k8s.EndpointContexts(raw, endpointMode)
+		if err != nil {
+			return nil, k8s.KubeconfigInfo{}, exit.New(1, err)
+		}
+	}
+	for i, name := range candidates {
+		kubeConfig, info, err := k8s.ResolveKubeconfigFromBytes(kubeconfigBytes, "rancher", paths, name, impersonate)
+		if err != nil {
+			return nil, info, exit.New(1, err)
+		}
+		if i == len(candidates)-1 {
+			return kubeConfig, info, nil
+		}
+		probeOpts := kubeOptions
+		probeOpts.Timeout = endpointProbeTimeout
+		client, err := k8s.NewClient(kubeConfig, probeOpts)
+		if err == nil {
+			_, err = client.ServerVersion(ctx)
+		}
+		if err == nil {
+			return kubeConfig, info, nil
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "endpoint context=%s cluster=%s unreachable, trying the next one: %v\n", name, clusterDisplayName(cluster), err)
+		}
+	}
+	return nil, k8s.KubeconfigInfo{}, exit.New(1, fmt.Errorf("no kubeconfig context for cluster %s", clusterDisplayName(cluster)))
+}
+
+func fetchClustersNodes(ctx context.Context, session *rancher.Session, cache *rancher.Cache, clusters []rancher.Cluster, query nodeQuery, parallel int) []clusterNodesResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]clusterNodesResult, len(clusters))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, cluster := range clusters {
+		wg.Add(1)
+		go func(i int, cluster rancher.Cluster) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			nodes, err := fetchClusterNodes(ctx, session, cache, cluster, query)
+			results[i] = clusterNodesResult{cluster: cluster, nodes: nodes, err: err}
+		}(i, cluster)
+	}
+	wg.Wait()
+	return results
+}
+
+func applyMachineNames(nodes []types.K8sNode, machines []rancher.Machine, clusterName string) {
+	nameByNode := rancher.MachineNameMap(machines, clusterName)
+	if len(nameByNode) == 0 {
+		return
+	}
+	for i := range nodes {
+		if name := nameByNode[nodes[i].Name]; name != "" {
+			nodes[i].MachineName = name
+		}
+	}
+}
+
+func tagNodesWithCluster(nodes []types.K8sNode, clusterName string) {
+	for i := range nodes {
+		nodes[i].Cluster = clusterName
+	}
+}
+
+func clusterDisplayName(cluster rancher.Cluster) string {
+	if cluster.Name != "" {
+		return cluster.Name
+	}
+	return cluster.ID
+}
+
+func filterClusters(clusters []rancher.Cluster, patterns []string, clusterSelector labels.Selector) ([]rancher.Cluster, error) {
+	matchers, err := buildClusterMatchers(patterns)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([]rancher.Cluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		if len(matchers) > 0 && !clusterMatchesAny(cluster, matchers) {
+			continue
+		}
+		if clusterSelector != nil && !clusterSelector.Matches(labels.Set(cluster.Labels)) {
+			continue
+		}
+		filtered = append(filtered, cluster)
+	}
+	return filtered, nil
+}
+
+func buildClusterMatchers(patterns []string) ([]labelMatcher, error) {
+	var matchers []labelMatcher
+	for _, raw := range patterns {
+		pattern := strings.TrimSpace(raw)
+		if pattern == "" {
+			continue
+		}
+		if expr, ok := regexPattern(pattern); ok {
+			rx, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cluster regex %q: %w", pattern, err)
+			}
+			matchers = append(matchers, rx.MatchString)
+			continue
+		}
+		if strings.ContainsAny(pattern, "*?") {
+			rx, err := regexp.Compile(wildcardToRegex(pattern))
+			if err != nil {
+				return nil, fmt.Errorf("invalid cluster pattern %q: %w", pattern, err)
+			}
+			matchers = append(matchers, rx.MatchString)
+			continue
+		}
+		matchers = append(matchers, func(value string) bool {
+			return value == pattern
+		})
+	}
+	return matchers, nil
+}
+
+func clusterMatchesAny(cluster rancher.Cluster, matchers []labelMatcher) bool {
+	for _, match := range matchers {
+		if match(cluster.Name) || match(cluster.ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the API calls made while completing, so an
+// unreachable Rancher or cluster does not hang the shell.
+const completionTimeout = 10 * time.Second
+
+type completionFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+func completeOutputModes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	modes := make([]string, 0, len(output.Modes))
+	for _, mode := range output.Modes {
+		modes = append(modes, string(mode))
+	}
+	return filterCompletions(modes, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeEndpointModes(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	modes := []string{string(k8s.EndpointProxy), string(k8s.EndpointDirect), string(k8s.EndpointAuto)}
+	return filterCompletions(modes, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeContexts(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if err := applyProfile(cmd); err != nil {
+		return completionError(err)
+	}
+	contexts, err := k8s.ListContexts(kubeconfigPath)
+	if err != nil {
+		return completionError(err)
+	}
+	return filterCompletions(contexts, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeClusters offers the names of the clusters known to Rancher, with
+// their IDs as descriptions.
+func (c *connector) completeClusters(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, err := c.connectForCompletion(cmd, args, true)
+	if err != nil {
+		return completionError(err)
+	}
+	defer cancel()
+	clusters, err := c.listClusters(ctx)
+	if err != nil {
+		return completionError(err)
+	}
+	var names []string
+	for _, cluster := range clusters {
+		if strings.HasPrefix(cluster.Name, toComplete) {
+			names = append(names, cluster.Name+"\t"+cluster.ID)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeLabelKeys offers the label keys of the nodes the command would
+// list, reusing the cached node listing when there is one.
+func (c *connector) completeLabelKeys(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	ctx, cancel, err := c.connectForCompletion(cmd, args, false)
+	if err != nil {
+		return completionError(err)
+	}
+	defer cancel()
+	nodes, _, err := c.listNodes(ctx, nodeQuery{metadataOnly: true})
+	if err != nil {
+		return completionError(err)
+	}
+	keys := slices.Sorted(maps.Keys(countLabelKeys(nodes)))
+	return filterCompletions(keys, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+func (c *connector) connectForCompletion(cmd *cobra.Command, args []string, needRancher bool) (context.Context, context.CancelFunc, error) {
+	if err := preRun(cmd, args); err != nil {
+		return nil, nil, err
+	}
+	c.completing = true
+	if needRancher {
+		// Cluster names only need Rancher; the kubeconfig may be absent.
+		c.optionalKube = true
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	if err := c.connect(ctx, cmd, needRancher); err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ctx, cancel, nil
+}
+
+// completeFirstArg completes the first positional argument only.
+func completeFirstArg(complete completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return complete(cmd, args, toComplete)
+	}
+}
+
+// completeCommaList completes the last item of a comma-separated flag value.
+func completeCommaList(complete completionFunc) completionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		prefix := ""
+		if i := strings.LastIndex(toComplete, ","); i >= 0 {
+			prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+		}
+		items, directive := complete(cmd, args, toComplete)
+		for i := range items {
+			items[i] = prefix + items[i]
+		}
+		return items, directive | cobra.ShellCompDirectiveNoSpace
+	}
+}
+
+func filterCompletions(values []string, toComplete string) []string {
+	var matches []string
+	for _, value := range values {
+		if strings.HasPrefix(value, toComplete) {
+			matches = append(matches, value)
+		}
+	}
+	return matches
+}
+
+func completionError(err error) ([]string, cobra.ShellCompDirective) {
+	cobra.CompDebugln(err.Error(), true)
+	return nil, cobra.ShellCompDirectiveError
+}
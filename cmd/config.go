@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/config"
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/output"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// defaultProfile is where config set writes when no profile is selected.
+const defaultProfile = "default"
+
+// applyProfile fills flags left unset on the command line from the selected
+// profile, unless an environment variable provides the value, giving
+// flag > env > profile > defaults.
+func applyProfile(cmd *cobra.Command) error {
+	path, file, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	name, profile, err := file.Profile(selectedProfile())
+	if err != nil {
+		return exit.New(1, err)
+	}
+	if name == "" {
+		return nil
+	}
+	flags := cmd.Flags()
+	for key, value := range profile {
+		setting, _ := config.Lookup(key)
+		flag := flags.Lookup(key)
+		if flag == nil || profileOverridden(flags, setting) {
+			continue
+		}
+		if err := flag.Value.Set(value); err != nil {
+			return exit.New(1, fmt.Errorf("profile %s: invalid %s: %w", name, key, err))
+		}
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "profile=%s config=%s\n", name, path)
+	}
+	return nil
+}
+
+func profileOverridden(flags *pflag.FlagSet, setting config.Setting) bool {
+	if flags.Changed(setting.Key) || slices.ContainsFunc(setting.Flags, flags.Changed) {
+		return true
+	}
+	for _, env := range setting.Env {
+		if os.Getenv(env) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func selectedProfile() string {
+	return firstNonEmpty(profileName, os.Getenv(config.ProfileEnv))
+}
+
+func loadConfig() (string, *config.File, error) {
+	path, err := config.DefaultPath()
+	if err != nil {
+		return "", nil, exit.New(1, err)
+	}
+	file, err := config.Load(path)
+	if err != nil {
+		return path, nil, exit.New(1, err)
+	}
+	return path, file, nil
+}
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "View and edit named profiles in the config file",
+		// Profiles are edited here, not applied.
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return validateErrorFlags(cmd)
+		},
+	}
+
+	cmd.AddCommand(newConfigViewCmd())
+	cmd.AddCommand(newConfigSetCmd())
+	cmd.AddCommand(newConfigUseProfileCmd())
+
+	return cmd
+}
+
+func newConfigViewCmd() *cobra.Command {
+	var (
+		outputMode  string
+		showSecrets bool
+	)
+	cmd := &cobra.Command{
+		Use:   "view",
+		Short: "Print the config file (tokens are redacted)",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := output.ParseMode(outputMode)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			path, file, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if !showSecrets {
+				file = file.Redacted()
+			}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "config=%s\n", path)
+			}
+			if mode == output.ModeJSON {
+				err = output.EmitJSON(file)
+			} else {
+				err = output.EmitYAML(file)
+			}
+			if err != nil {
+				return exit.New(1, err)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&outputMode, "output", "yaml", "output format: yaml|json")
+	cmd.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{string(output.ModeYAML), string(output.ModeJSON)}, cobra.ShellCompDirectiveNoFileComp))
+	cmd.Flags().BoolVar(&showSecrets, "show-secrets", false, "print tokens instead of redacting them")
+
+	return cmd
+}
+
+func newConfigSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a profile setting (an empty value removes it)",
+		Long: "Set a setting in the --profile profile, else the current profile, else \"default\". " +
+			"Keys are named after the flags they default; a profile is created on first use and " +
+			"becomes current if no profile is.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.DefaultPath()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			var name string
+			err = config.Update(path, func(file *config.File) error {
+				name = firstNonEmpty(selectedProfile(), file.CurrentProfile, defaultProfile)
+				if err := file.Set(name, args[0], args[1]); err != nil {
+					return err
+				}
+				if file.CurrentProfile == "" {
+					file.CurrentProfile = name
+				}
+				return nil
+			})
+			if err != nil {
+				return exit.New(1, err)
+			}
+			fmt.Fprintf(os.Stdout, "Set %s in profile %s (%s)\n", args[0], name, path)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newConfigUseProfileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "use-profile <name>",
+		Short: "Make a profile the current one",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.DefaultPath()
+			if err != nil {
+				return exit.New(1, err)
+			}
+			err = config.Update(path, func(file *config.File) error {
+				return file.UseProfile(args[0])
+			})
+			if err != nil {
+				return exit.New(1, err)
+			}
+			fmt.Fprintf(os.Stdout, "Current profile is now %s\n", args[0])
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// connector resolves what a command talks to: the local kubeconfig, the
+// Rancher server (explicit or derived from that kubeconfig) and, with
+// --rancher-cluster, a downstream cluster whose kubeconfig Rancher generates.
+type connector struct {
+	rancher rancherOptions
+	cluster string
+	// allClusters is set by match; nodes then come from every Rancher cluster.
+	allClusters bool
+	// multiContext is set by match; with --contexts or a repeated --context
+	// nodes then come from every selected kubeconfig context.
+	multiContext bool
+	// optionalKube tolerates a missing kubeconfig when the Rancher URL and
+	// token are known, for commands that report it separately (doctor).
+	optionalKube bool
+	// completing is set by shell completion, which must not create
+	// credentials: token commands, exec plugins and kubeconfig generation
+	// are only used through their caches.
+	completing bool
+
+	kubeConfig clientcmd.ClientConfig
+	kubeInfo   k8s.KubeconfigInfo
+	contexts   []string
+	haveKube   bool
+	session    *rancher.Session
+	cache      *rancher.Cache
+}
+
+func (c *connector) addFlags(cmd *cobra.Command) {
+	c.rancher.addFlags(cmd)
+	c.rancher.addTokenFlags(cmd)
+	cmd.Flags().StringVar(&c.cluster, "rancher-cluster", "", "downstream cluster name or ID (resolved via Rancher)")
+	cmd.RegisterFlagCompletionFunc("rancher-cluster", c.completeClusters)
+}
+
+func (c *connector) rancherNodes() bool {
+	return c.allClusters || c.cluster != ""
+}
+
+// connect resolves the kubeconfig and cache, and the Rancher session when
+// needRancher is set or nodes come from Rancher-managed clusters.
+func (c *connector) connect(ctx context.Context, cmd *cobra.Command, needRancher bool) error {
+	c.rancher.applyEnv(cmd)
+	c.cluster = firstNonEmpty(c.cluster, os.Getenv("RANCHER_CLUSTER"))
+	if c.allClusters {
+		c.cluster = ""
+	}
+	if multipleContexts() {
+		if !c.multiContext {
+			return exit.New(1, fmt.Errorf("--contexts and a repeated --context are only supported by match"))
+		}
+		if c.rancherNodes() {
+			return exit.New(1, fmt.Errorf("--contexts and a repeated --context cannot be combined with --rancher-cluster or --all-clusters"))
+		}
+		available, err := k8s.ListContexts(kubeconfigPath)
+		if err != nil {
+			return exit.New(1, err)
+		}
+		c.contexts, err = k8s.SelectContexts(available, kubeContexts, contextGlob)
+		if err != nil {
+			return exit.New(1, err)
+		}
+		if verbose {
+			fmt.Fprintf(os.Stderr, "kubeconfig contexts=%s\n", strings.Join(c.contexts, ","))
+		}
+	}
+	needRancher = needRancher || c.rancherNodes()
+	if needRancher {
+		if err := c.rancher.resolveToken(ctx, c.completing); err != nil {
+			return exit.New(1, err)
+		}
+		c.rancher.applyStoredToken()
+	}
+
+	if !c.rancherNodes() || c.rancher.url == "" || c.rancher.token == "" {
+		var err error
+		c.kubeConfig, c.kubeInfo, err = k8s.ResolveKubeconfig(kubeconfigPath, kubeContext, impersonate)
+		switch {
+		case err == nil:
+			c.haveKube = true
+			if verbose {
+				fmt.Fprintln(os.Stderr, k8s.DescribeKubeconfig(c.kubeInfo))
+			}
+		case (!c.optionalKube && len(c.contexts) == 0) || c.rancher.url == "" || c.rancher.token == "":
+			return exit.New(1, err)
+		}
+	}
+
+	var err error
+	c.cache, err = openCache()
+	if err != nil {
+		return exit.New(1, err)
+	}
+	if !needRancher {
+		return nil
+	}
+
+	sessionOpts := c.rancher.sessionOptions()
+	if c.rancher.url == "" || c.rancher.token == "" {
+		if !c.haveKube {
+			return exit.New(1, fmt.Errorf("rancher URL or token missing and kubeconfig unavailable"))
+		}
+		// A nil *rancher.Cache must not become a non-nil interface.
+		var creds k8s.CredentialCache
+		if c.cache != nil {
+			creds = c.cache
+		}
+		server, token, err := k8s.ExtractServerAndToken(c.kubeConfig, c.kubeInfo.Context, k8s.ExecPolicy{Cache: creds, CachedOnly: c.completing})
+		if err != nil {
+			return exit.New(1, err)
+		}
+		if c.rancher.url == "" {
+			derived, err := rancher.BaseURLFromServer(server)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			c.rancher.url = derived
+			if verbose {
+				fmt.Fprintf(os.Stderr, "rancher url from kubeconfig=%s\n", c.rancher.url)
+			}
+			if sessionOpts.CAFile == "" {
+				caData, err := k8s.ExtractCertificateAuthority(c.kubeConfig, c.kubeInfo.Context)
+				if err != nil {
+					return exit.New(1, err)
+				}
+				sessionOpts.CAData = caData
+				if verbose && len(caData) > 0 {
+					fmt.Fprintln(os.Stderr, "rancher CA from kubeconfig")
+				}
+			}
+		}
+		c.rancher.applyStoredToken()
+		if c.rancher.token == "" {
+			c.rancher.token = token
+			if verbose {
+				fmt.Fprintln(os.Stderr, "rancher token from kubeconfig")
+			}
+		}
+	}
+
+	if c.rancher.url == "" {
+		return exit.New(1, fmt.Errorf("rancher URL is required (use --rancher-url or RANCHER_URL)"))
+	}
+	if c.rancher.token == "" {
+		return exit.New(1, fmt.Errorf("rancher token is required (use --rancher-token, RANCHER_TOKEN or login)"))
+	}
+
+	c.session, err = rancher.NewSession(c.rancher.url, c.rancher.token, sessionOpts)
+	if err != nil {
+		return exit.New(1, err)
+	}
+	return nil
+}
+
+// finish reports session statistics under --verbose.
+func (c *connector) finish() {
+	if verbose && c.session != nil {
+		fmt.Fprintf(os.Stderr, "rancher requests retried=%d\n", c.session.RetryCount())
+	}
+}
+
+func (c *connector) listClusters(ctx context.Context) ([]rancher.Cluster, error) {
+	return cachedList(c.cache, "clusters", c.session.ResultCacheName("clusters"), func() ([]rancher.Cluster, error) {
+		return c.session.Clusters().ListClusters(ctx)
+	})
+}
+
+func (c *connector) resolveCluster(ctx context.Context) (rancher.Cluster, error) {
+	clusters, err := c.listClusters(ctx)
+	if err != nil {
+		return rancher.Cluster{}, exit.New(2, err)
+	}
+	cluster, err := rancher.FindCluster(clusters, c.cluster)
+	if err != nil {
+		return rancher.Cluster{}, exit.New(1, err)
+	}
+	return cluster, nil
+}
+
+// listNodes runs query against the nodes of the --rancher-cluster downstream
+// cluster, or against the kubeconfig cluster when none was requested. The
+// returned cluster is zero in the latter case.
+func (c *connector) listNodes(ctx context.Context, query nodeQuery) ([]types.K8sNode, rancher.Cluster, error) {
+	query.cachedOnly = c.completing
+	if c.cluster != "" {
+		cluster, err := c.resolveCluster(ctx)
+		if err != nil {
+			return nil, rancher.Cluster{}, err
+		}
+		nodes, err := fetchClusterNodes(ctx, c.session, c.cache, cluster, query)
+		return nodes, cluster, err
+	}
+	if !c.haveKube {
+		return nil, rancher.Cluster{}, exit.New(1, fmt.Errorf("kubeconfig is required to list nodes"))
+	}
+	nodes, err := listKubeconfigNodes(ctx, c.cache, c.kubeConfig, c.kubeInfo, query)
+	return nodes, rancher.Cluster{}, err
+}
+
+// downstreamKubeconfig returns the kubeconfig of the --rancher-cluster
+// downstream cluster, or the local kubeconfig when none was requested.
+func (c *connector) downstreamKubeconfig(ctx context.Context) (clientcmd.ClientConfig, rancher.Cluster, error) {
+	if c.cluster == "" {
+		if !c.haveKube {
+			return nil, rancher.Cluster{}, exit.New(1, fmt.Errorf("kubeconfig is required to list nodes"))
+		}
+		return c.kubeConfig, rancher.Cluster{}, nil
+	}
+	cluster, err := c.resolveCluster(ctx)
+	if err != nil {
+		return nil, rancher.Cluster{}, err
+	}
+	kubeconfigBytes, _, err := clusterKubeconfig(ctx, c.session, c.cache, cluster)
+	if err != nil {
+		return nil, rancher.Cluster{}, err
+	}
+	kubeConfig, info, err := downstreamClientConfig(ctx, kubeconfigBytes, cluster)
+	if err != nil {
+		return nil, rancher.Cluster{}, err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "%s cluster=%s\n", k8s.DescribeKubeconfig(info), cluster.Name)
+	}
+	return kubeConfig, cluster, nil
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+)
+
+type contextNodesResult struct {
+	context string
+	nodes   []types.K8sNode
+	err     error
+}
+
+// fetchContextsNodes lists the nodes of each kubeconfig context, at most
+// parallel at a time, and tags them with the context name as their cluster.
+func fetchContextsNodes(ctx context.Context, cache *rancher.Cache, contexts []string, query nodeQuery, parallel int) []contextNodesResult {
+	if parallel < 1 {
+		parallel = 1
+	}
+	results := make([]contextNodesResult, len(contexts))
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i, name := range contexts {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			nodes, err := fetchContextNodes(ctx, cache, name, query)
+			results[i] = contextNodesResult{context: name, nodes: nodes, err: err}
+		}(i, name)
+	}
+	wg.Wait()
+	return results
+}
+
+func fetchContextNodes(ctx context.Context, cache *rancher.Cache, name string, query nodeQuery) ([]types.K8sNode, error) {
+	kubeConfig, info, err := k8s.ResolveKubeconfig(kubeconfigPath, name, impersonate)
+	if err != nil {
+		return nil, exit.New(1, err)
+	}
+	if verbose {
+		fmt.Fprintln(os.Stderr, k8s.DescribeKubeconfig(info))
+	}
+	nodes, err := listKubeconfigNodes(ctx, cache, kubeConfig, info, query)
+	if err != nil {
+		return nil, err
+	}
+	tagNodesWithCluster(nodes, name)
+	return nodes, nil
+}
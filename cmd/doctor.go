@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/output"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func newDoctorCmd() *cobra.Command {
+	var (
+		conn       connector
+		outputMode string
+	)
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check kubeconfig, Rancher and RBAC prerequisites",
+		Long: "Run preflight checks for match: kubeconfig resolution, downstream API reachability and node RBAC, " +
+			"Rancher authentication, the MachineInventory and Machine schemas and, with --rancher-cluster, " +
+			"permission to generate the cluster kubeconfig. Downstream checks for --rancher-cluster use the cached kubeconfig; " +
+			"doctor never generates one.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mode, err := output.ParseMode(outputMode)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			ctx := context.Background()
+			conn.optionalKube = true
+
+			var checks []output.Check
+			pass := func(name, detail string) {
+				checks = append(checks, output.Check{Name: name, Status: output.CheckPass, Detail: detail})
+			}
+			warn := func(name, detail, hint string) {
+				checks = append(checks, output.Check{Name: name, Status: output.CheckWarn, Detail: detail, Hint: hint})
+			}
+			skip := func(name, detail string) {
+				checks = append(checks, output.Check{Name: name, Status: output.CheckSkip, Detail: detail})
+			}
+			fail := func(name string, err error) {
+				checks = append(checks, output.Check{Name: name, Status: output.CheckFail, Detail: err.Error(), Hint: describeError(err).Hint})
+			}
+
+			kubeConfig, kubeInfo, kubeErr := k8s.ResolveKubeconfig(kubeconfigPath, kubeContext, impersonate)
+			if kubeErr != nil {
+				fail("kubeconfig", kubeErr)
+			} else {
+				pass("kubeconfig", k8s.DescribeKubeconfig(kubeInfo))
+			}
+
+			rancherErr := conn.connect(ctx, cmd, true)
+			if rancherErr != nil {
+				fail("rancher config", rancherErr)
+			} else {
+				defer conn.finish()
+				pass("rancher config", conn.session.BaseURL())
+			}
+
+			authenticated := false
+			if rancherErr == nil {
+				user, err := conn.session.CurrentUser(ctx)
+				if err != nil {
+					fail("rancher auth", err)
+				} else {
+					authenticated = true
+					detail := "authenticated as " + user
+					if as := impersonate.UserName; as != "" {
+						detail += ", impersonating " + as
+					}
+					pass("rancher auth", detail)
+				}
+			} else {
+				skip("rancher auth", "rancher is not configured")
+			}
+
+			schemas := []struct {
+				name, id string
+				required bool
+			}{
+				{"machineinventories schema", rancher.InventorySchemaID, true},
+				{"machines schema", rancher.MachineSchemaID, false},
+			}
+			for _, schema := range schemas {
+				if !authenticated {
+					skip(schema.name, "rancher authentication failed or was skipped")
+					continue
+				}
+				found, err := conn.session.HasSchema(ctx, schema.id)
+				switch {
+				case err != nil:
+					fail(schema.name, err)
+				case found:
+					pass(schema.name, schema.id)
+				case schema.required:
+					fail(schema.name, &rancher.APIError{Kind: rancher.ErrCRDNotInstalled, Message: schema.id + " is not served by Rancher"})
+				default:
+					warn(schema.name, schema.id+" is not served by Rancher", "Rancher machine names will not be shown")
+				}
+			}
+
+			var downstream clientcmd.ClientConfig
+			target := "kubeconfig cluster"
+			noDownstream := "no downstream kubeconfig"
+			var downstreamErr error
+			switch {
+			case conn.cluster == "":
+				skip("kubeconfig generation", "no --rancher-cluster given")
+				if kubeErr == nil {
+					downstream = kubeConfig
+				}
+			case !authenticated:
+				skip("rancher cluster", "rancher authentication failed or was skipped")
+				skip("kubeconfig generation", "rancher authentication failed or was skipped")
+			default:
+				cluster, err := conn.resolveCluster(ctx)
+				if err != nil {
+					fail("rancher cluster", err)
+					skip("kubeconfig generation", "cluster not resolved")
+					break
+				}
+				target = clusterDisplayName(cluster)
+				pass("rancher cluster", fmt.Sprintf("%s (%s)", cluster.Name, cluster.ID))
+				allowed, err := conn.session.CanGenerateKubeconfig(ctx, cluster.ID)
+				switch {
+				case err != nil:
+					fail("kubeconfig generation", err)
+				case !allowed:
+					fail("kubeconfig generation", &rancher.APIError{Kind: rancher.ErrKubeconfigUnavailable, Message: "generateKubeconfig is not offered for " + cluster.ID})
+				default:
+					pass("kubeconfig generation", "allowed for "+cluster.ID)
+				}
+				// Generating a kubeconfig mints a Rancher token, so the
+				// downstream checks only use one match already cached.
+				downstream, downstreamErr = cachedDownstreamKubeconfig(ctx, conn.session, conn.cache, cluster)
+				if downstream == nil && downstreamErr == nil {
+					noDownstream = "no cached kubeconfig; run match once"
+				}
+			}
+
+			if downstream == nil {
+				if downstreamErr != nil {
+					fail("kubernetes API", downstreamErr)
+					noDownstream = "cached kubeconfig is unusable"
+				} else {
+					skip("kubernetes API", noDownstream)
+				}
+				skip("list nodes", noDownstream)
+				skip("watch nodes", noDownstream)
+			} else {
+				checkDownstream(ctx, downstream, target, pass, warn, fail)
+			}
+
+			if err := output.RenderChecks(checks, mode); err != nil {
+				return exit.New(1, err)
+			}
+			failed := 0
+			for _, check := range checks {
+				if check.Status == output.CheckFail {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return &exit.Error{Code: 2, Kind: "doctor_failed", Err: fmt.Errorf("%d of %d checks failed", failed, len(checks))}
+			}
+			return nil
+		},
+	}
+
+	conn.addFlags(cmd)
+	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
+	return cmd
+}
+
+func checkDownstream(ctx context.Context, kubeConfig clientcmd.ClientConfig, target string, pass func(string, string), warn func(string, string, string), fail func(string, error)) {
+	client, err := k8s.NewClient(kubeConfig, kubeOptions)
+	if err != nil {
+		fail("kubernetes API", err)
+		return
+	}
+	version, err := client.ServerVersion(ctx)
+	if err != nil {
+		fail("kubernetes API", err)
+		return
+	}
+	pass("kubernetes API", fmt.Sprintf("%s reachable, version %s", target, version))
+
+	allowed, reason, err := client.CanI(ctx, "list", "nodes")
+	switch {
+	case err != nil:
+		fail("list nodes", err)
+	case !allowed:
+		fail("list nodes", &k8s.APIError{Kind: k8s.ErrForbidden, Err: fmt.Errorf("list nodes denied%s", reasonSuffix(reason))})
+	default:
+		pass("list nodes", "allowed")
+	}
+
+	allowed, reason, err = client.CanI(ctx, "watch", "nodes")
+	switch {
+	case err != nil:
+		fail("watch nodes", err)
+	case !allowed:
+		warn("watch nodes", "watch nodes denied"+reasonSuffix(reason), "match --watch needs watch permission on nodes")
+	default:
+		pass("watch nodes", "allowed")
+	}
+}
+
+func reasonSuffix(reason string) string {
+	if reason == "" {
+		return ""
+	}
+	return ": " + reason
+}
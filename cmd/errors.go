@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/output"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/spf13/cobra"
+)
+
+var (
+	errorFormat string
+	errorOutput string
+	// jsonOutput records that the running command renders JSON, so errors
+	// follow suit when --error-format is auto.
+	jsonOutput bool
+)
+
+func addErrorFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&errorFormat, "error-format", "auto", "error format: auto|text|json (auto uses json with --output json)")
+	cmd.PersistentFlags().StringVar(&errorOutput, "error-output", "stderr", "where errors are written: stderr|stdout")
+}
+
+func validateErrorFlags(cmd *cobra.Command) error {
+	switch errorFormat {
+	case "auto", "text", "json":
+	default:
+		return exit.New(1, fmt.Errorf("invalid error format: %s", errorFormat))
+	}
+	switch errorOutput {
+	case "stderr", "stdout":
+	default:
+		return exit.New(1, fmt.Errorf("invalid error output: %s", errorOutput))
+	}
+	if flag := cmd.Flags().Lookup("output"); flag != nil && flag.Value.String() == string(output.ModeJSON) {
+		jsonOutput = true
+	}
+	return nil
+}
+
+// ReportError writes err in the selected format and returns the process exit code.
+func ReportError(err error) int {
+	described := describeError(err)
+
+	var w io.Writer = os.Stderr
+	if errorOutput == "stdout" {
+		w = os.Stdout
+	}
+	if errorFormat == "json" || (errorFormat == "auto" && jsonOutput) {
+		if renderErr := output.RenderErrorJSON(w, described); renderErr == nil {
+			return described.Code
+		}
+	}
+	fmt.Fprintln(w, described.Message)
+	if described.Hint != "" {
+		fmt.Fprintf(w, "hint: %s\n", described.Hint)
+	}
+	return described.Code
+}
+
+func describeError(err error) output.ErrorOutput {
+	described := output.ErrorOutput{Code: 1, Kind: "invalid_input", Message: err.Error()}
+
+	var exitErr *exit.Error
+	if errors.As(err, &exitErr) {
+		described.Code = exitErr.Code
+		described.Partial = exitErr.Partial
+		described.Failures = exitErr.Failures
+		if exitErr.Code == 2 {
+			described.Kind = "api_error"
+		}
+	}
+
+	var rancherErr *rancher.APIError
+	var k8sAPIErr *k8s.APIError
+	var k8sConfigErr *k8s.ConfigError
+	switch {
+	case errors.As(err, &rancherErr):
+		described.Kind = string(rancherErr.Kind)
+		described.Source = "rancher"
+		described.Endpoint = rancherErr.Endpoint
+		described.RequestID = rancherErr.RequestID
+		described.Hint = rancherErr.Hint()
+		if rancherErr.Kind == rancher.ErrForbidden && impersonate.UserName != "" {
+			described.Hint = fmt.Sprintf("Steve requests ran as %s; check that user's Rancher permissions, and that the token's user may impersonate users and groups", impersonate.UserName)
+		}
+	case errors.As(err, &k8sAPIErr):
+		described.Kind = string(k8sAPIErr.Kind)
+		described.Source = "kubernetes"
+		if k8sAPIErr.Kind == k8s.ErrForbidden && impersonate.UserName != "" {
+			described.Hint = fmt.Sprintf("the request ran as %s; check that user's RBAC, and that your own credentials may impersonate users and groups", impersonate.UserName)
+		}
+	case errors.As(err, &k8sConfigErr):
+		described.Kind = string(k8sConfigErr.Kind)
+		described.Source = "kubeconfig"
+	}
+	if exitErr != nil && exitErr.Kind != "" {
+		described.Kind = exitErr.Kind
+	}
+	return described
+}
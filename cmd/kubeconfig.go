@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func newKubeconfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Work with Rancher-generated downstream kubeconfigs",
+	}
+	cmd.AddCommand(newKubeconfigExportCmd())
+	return cmd
+}
+
+func newKubeconfigExportCmd() *cobra.Command {
+	var (
+		conn        connector
+		file        string
+		merge       bool
+		contextName string
+		replace     bool
+	)
+	cmd := &cobra.Command{
+		Use:               "export <cluster>",
+		Short:             "Write the Rancher-generated kubeconfig of a downstream cluster to stdout, a file or your kubeconfig",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFirstArg(conn.completeClusters),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if file != "" && merge {
+				return exit.New(1, fmt.Errorf("--file cannot be combined with --merge"))
+			}
+			ctx := context.Background()
+			conn.cluster = args[0]
+			if err := conn.connect(ctx, cmd, true); err != nil {
+				return err
+			}
+			defer conn.finish()
+
+			cluster, err := conn.resolveCluster(ctx)
+			if err != nil {
+				return err
+			}
+			kubeconfigBytes, _, err := clusterKubeconfig(ctx, conn.session, conn.cache, cluster)
+			if err != nil {
+				return err
+			}
+			generated, err := clientcmd.Load(kubeconfigBytes)
+			if err != nil {
+				return exit.New(2, &k8s.ConfigError{Kind: k8s.ErrKubeconfigInvalid, Paths: []string{"cluster:" + cluster.ID}, Err: err})
+			}
+
+			// The exported context is the one match would use, per --endpoint.
+			_, info, err := downstreamClientConfig(ctx, kubeconfigBytes, cluster)
+			if err != nil {
+				return err
+			}
+			source := info.Context
+			name := firstNonEmpty(contextName, clusterDisplayName(cluster))
+			exported, err := k8s.ExtractContext(generated, source, name)
+			if err != nil {
+				return exit.New(2, err)
+			}
+			if verbose {
+				fmt.Fprintf(os.Stderr, "exporting context=%s as %s cluster=%s\n", source, name, cluster.ID)
+			}
+
+			switch {
+			case merge:
+				return mergeExportedKubeconfig(exported, name, replace)
+			case file != "":
+				if _, err := os.Stat(file); err == nil && !replace {
+					return exit.New(1, fmt.Errorf("%s already exists (use --replace to overwrite it)", file))
+				}
+				if err := clientcmd.WriteToFile(*exported, file); err != nil {
+					return exit.New(1, err)
+				}
+				fmt.Fprintf(os.Stdout, "Kubeconfig for %s written to %s (context %s)\n", clusterDisplayName(cluster), file, name)
+				return nil
+			default:
+				content, err := clientcmd.Write(*exported)
+				if err != nil {
+					return exit.New(1, err)
+				}
+				_, err = os.Stdout.Write(content)
+				return err
+			}
+		},
+	}
+
+	conn.rancher.addFlags(cmd)
+	conn.rancher.addTokenFlags(cmd)
+	cmd.Flags().StringVar(&file, "file", "", "write a standalone kubeconfig to this path instead of stdout")
+	cmd.Flags().BoolVar(&merge, "merge", false, "merge into your kubeconfig (--kubeconfig, the first $KUBECONFIG entry or ~/.kube/config)")
+	cmd.Flags().StringVar(&contextName, "context-name", "", "name for the exported context, cluster and user (default: the cluster name)")
+	cmd.Flags().BoolVar(&replace, "replace", false, "overwrite an existing --file, or existing kubeconfig entries with the same name")
+	return cmd
+}
+
+// mergeExportedKubeconfig adds exported to the user's kubeconfig without
+// touching other entries or the current context.
+func mergeExportedKubeconfig(exported *clientcmdapi.Config, name string, replace bool) error {
+	path := k8s.WritePath(kubeconfigPath)
+	existing, err := clientcmd.LoadFromFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		existing = clientcmdapi.NewConfig()
+	case err != nil:
+		return exit.New(1, &k8s.ConfigError{Kind: k8s.ErrKubeconfigInvalid, Paths: []string{path}, Err: err})
+	}
+	if err := k8s.MergeKubeconfig(existing, exported, replace); err != nil {
+		if errors.Is(err, k8s.ErrEntryExists) {
+			return exit.New(1, fmt.Errorf("%w in %s (choose another --context-name or pass --replace)", err, path))
+		}
+		return exit.New(1, err)
+	}
+	if err := clientcmd.WriteToFile(*existing, path); err != nil {
+		return exit.New(1, err)
+	}
+	fmt.Fprintf(os.Stdout, "Context %s merged into %s\n", name, path)
+	return nil
+}
@@ -2,11 +2,8 @@ package cmd
 
 import (
 	"context"
-	"fmt"
-	"os"
 
 	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
-	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
 	"github.com/goldyfruit/elemental-node-mapper/internal/output"
 	"github.com/goldyfruit/elemental-node-mapper/internal/types"
 	"github.com/spf13/cobra"
@@ -25,7 +22,10 @@ func newLabelsCmd() *cobra.Command {
 }
 
 func newLabelsKeysCmd() *cobra.Command {
-	var outputMode string
+	var (
+		outputMode string
+		conn       connector
+	)
 	cmd := &cobra.Command{
 		Use:   "keys",
 		Short: "List node label keys",
@@ -35,23 +35,15 @@ func newLabelsKeysCmd() *cobra.Command {
 				return exit.New(1, err)
 			}
 
-			kubeConfig, info, err := k8s.ResolveKubeconfig(kubeconfigPath, kubeContext)
-			if err != nil {
-				return exit.New(1, err)
-			}
-			if verbose {
-				fmt.Fprintln(os.Stderr, k8s.DescribeKubeconfig(info))
-			}
-
-			client, err := k8s.NewClient(kubeConfig)
-			if err != nil {
-				return exit.New(1, err)
+			ctx := context.Background()
+			if err := conn.connect(ctx, cmd, false); err != nil {
+				return err
 			}
+			defer conn.finish()
 
-			ctx := context.Background()
-			nodes, err := client.ListNodes(ctx, nil)
+			nodes, _, err := conn.listNodes(ctx, nodeQuery{metadataOnly: true})
 			if err != nil {
-				return exit.New(2, err)
+				return err
 			}
 
 			counts := countLabelKeys(nodes)
@@ -62,39 +54,37 @@ func newLabelsKeysCmd() *cobra.Command {
 		},
 	}
 
+	conn.addFlags(cmd)
 	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
 	return cmd
 }
 
 func newLabelsValuesCmd() *cobra.Command {
-	var outputMode string
+	var (
+		outputMode string
+		conn       connector
+	)
 	cmd := &cobra.Command{
-		Use:   "values <key>",
-		Short: "List values for a label key",
-		Args:  cobra.ExactArgs(1),
+		Use:               "values <key>",
+		Short:             "List values for a label key",
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completeFirstArg(conn.completeLabelKeys),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			mode, err := output.ParseMode(outputMode)
 			if err != nil {
 				return exit.New(1, err)
 			}
 
-			kubeConfig, info, err := k8s.ResolveKubeconfig(kubeconfigPath, kubeContext)
-			if err != nil {
-				return exit.New(1, err)
-			}
-			if verbose {
-				fmt.Fprintln(os.Stderr, k8s.DescribeKubeconfig(info))
-			}
-
-			client, err := k8s.NewClient(kubeConfig)
-			if err != nil {
-				return exit.New(1, err)
+			ctx := context.Background()
+			if err := conn.connect(ctx, cmd, false); err != nil {
+				return err
 			}
+			defer conn.finish()
 
-			ctx := context.Background()
-			nodes, err := client.ListNodes(ctx, nil)
+			nodes, _, err := conn.listNodes(ctx, nodeQuery{metadataOnly: true})
 			if err != nil {
-				return exit.New(2, err)
+				return err
 			}
 
 			key := args[0]
@@ -106,7 +96,9 @@ func newLabelsValuesCmd() *cobra.Command {
 		},
 	}
 
+	conn.addFlags(cmd)
 	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
 	return cmd
 }
 
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func newLoginCmd() *cobra.Command {
+	var (
+		opts          rancherOptions
+		username      string
+		passwordStdin bool
+		provider      string
+		ttl           time.Duration
+		description   string
+		clusterScope  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to Rancher and store a scoped API token",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.applyEnv(cmd)
+			username = firstNonEmpty(username, os.Getenv("RANCHER_USERNAME"))
+			if opts.url == "" {
+				return exit.New(1, fmt.Errorf("rancher URL is required (use --rancher-url or RANCHER_URL)"))
+			}
+			if ttl < 0 {
+				return exit.New(1, fmt.Errorf("--ttl must not be negative"))
+			}
+
+			stdin := bufio.NewReader(os.Stdin)
+			if username == "" {
+				if passwordStdin || !term.IsTerminal(int(os.Stdin.Fd())) {
+					return exit.New(1, fmt.Errorf("username is required (use --username or RANCHER_USERNAME)"))
+				}
+				fmt.Fprint(os.Stderr, "Username: ")
+				line, err := stdin.ReadString('\n')
+				if err != nil && err != io.EOF {
+					return exit.New(1, err)
+				}
+				username = strings.TrimSpace(line)
+			}
+			password, err := readPassword(stdin, passwordStdin)
+			if err != nil {
+				return exit.New(1, err)
+			}
+
+			session, err := rancher.NewSession(opts.url, "", opts.sessionOptions())
+			if err != nil {
+				return exit.New(1, err)
+			}
+
+			ctx := context.Background()
+			loginToken, err := session.Login(ctx, provider, username, password)
+			if err != nil {
+				return exit.New(2, err)
+			}
+			authed := session.WithToken(loginToken)
+			defer func() {
+				if err := authed.Logout(ctx); err != nil && verbose {
+					fmt.Fprintf(os.Stderr, "rancher logout failed: %v\n", err)
+				}
+			}()
+
+			request := rancher.TokenRequest{Description: description, TTL: ttl}
+			if clusterScope != "" {
+				cluster, err := authed.Clusters().ResolveCluster(ctx, clusterScope)
+				if err != nil {
+					return exit.New(2, err)
+				}
+				request.ClusterID = cluster.ID
+			}
+			token, err := authed.CreateToken(ctx, request)
+			if err != nil {
+				return exit.New(2, err)
+			}
+
+			stored := rancher.StoredToken{
+				RancherURL: session.BaseURL(),
+				Name:       token.Name,
+				Token:      token.Token,
+				ExpiresAt:  token.ExpiresAt,
+				CreatedAt:  time.Now().UTC(),
+			}
+			if err := rancher.SaveStoredToken(stored); err != nil {
+				return exit.New(1, fmt.Errorf("failed to store rancher token: %w", err))
+			}
+
+			expires := "never"
+			if !token.ExpiresAt.IsZero() {
+				expires = token.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(os.Stdout, "Logged in to %s as %s (token %s, expires %s)\n", session.BaseURL(), username, token.Name, expires)
+			return nil
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().StringVar(&username, "username", "", "Rancher username (defaults to RANCHER_USERNAME)")
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from stdin")
+	cmd.Flags().StringVar(&provider, "provider", "local", "auth provider: "+strings.Join(rancher.AuthProviders(), ", "))
+	cmd.Flags().DurationVar(&ttl, "ttl", 720*time.Hour, "lifetime of the API token (0 uses the Rancher default)")
+	cmd.Flags().StringVar(&description, "description", "elemental-node-map", "description attached to the API token")
+	cmd.Flags().StringVar(&clusterScope, "cluster", "", "scope the API token to a single cluster (name or ID)")
+
+	return cmd
+}
+
+func readPassword(stdin *bufio.Reader, fromStdin bool) (string, error) {
+	if fromStdin {
+		line, err := stdin.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("no password provided on stdin")
+		}
+		return password, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("stdin is not a terminal (use --password-stdin)")
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
@@ -9,21 +9,14 @@ import (
 	"time"
 
 	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
-	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
 	"github.com/goldyfruit/elemental-node-mapper/internal/match"
 	"github.com/goldyfruit/elemental-node-mapper/internal/output"
 	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
 	"github.com/goldyfruit/elemental-node-mapper/internal/selector"
 	"github.com/goldyfruit/elemental-node-mapper/internal/types"
 	"github.com/spf13/cobra"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
-type hostResult struct {
-	hosts []types.InventoryHost
-	err   error
-}
-
 type machineResult struct {
 	machines []rancher.Machine
 	err      error
@@ -31,16 +24,22 @@ type machineResult struct {
 
 func newMatchCmd() *cobra.Command {
 	var (
-		rancherURL     string
-		rancherToken   string
-		rancherCluster string
-		labelSearch    string
-		selectorRaw    string
-		showUnmatched  bool
-		explain        bool
-		wide           bool
-		outputMode     string
-		insecureTLS    bool
+		conn          connector
+		state         nodeStateFilter
+		labelSearch   string
+		selectorRaw   string
+		showUnmatched bool
+		explain       bool
+		wide          bool
+		outputMode    string
+		allClusters   bool
+		clusterFilter string
+		clusterSelRaw string
+		parallel      int
+		inventoryNS   string
+		inventorySel  string
+		watch         bool
+		watchInterval time.Duration
 	)
 
 	cmd := &cobra.Command{
@@ -57,149 +56,183 @@ func newMatchCmd() *cobra.Command {
 				return exit.New(1, err)
 			}
 
-			rancherURL = firstNonEmpty(rancherURL, os.Getenv("RANCHER_URL"))
-			rancherToken = firstNonEmpty(rancherToken, os.Getenv("RANCHER_TOKEN"))
-			rancherCluster = firstNonEmpty(rancherCluster, os.Getenv("RANCHER_CLUSTER"))
-
-			if !cmd.Flags().Changed("insecure-skip-tls-verify") {
-				if env := os.Getenv("RANCHER_INSECURE_SKIP_TLS_VERIFY"); env == "true" || env == "1" {
-					insecureTLS = true
-				}
+			clusterSelector, err := selector.Parse(clusterSelRaw)
+			if err != nil {
+				return exit.New(1, err)
 			}
 
-			ctx, cancel := context.WithCancel(context.Background())
-			defer cancel()
-
-			var (
-				nodes       []types.K8sNode
-				clusterName string
-				kubeConfig  clientcmd.ClientConfig
-				kubeInfo    k8s.KubeconfigInfo
-				haveKube    bool
-			)
+			inventorySelector, err := selector.Parse(inventorySel)
+			if err != nil {
+				return exit.New(1, err)
+			}
+			inventoryOpts := rancher.InventoryOptions{Namespace: inventoryNS, Selector: inventorySelector}
 
-			if rancherCluster == "" || rancherURL == "" || rancherToken == "" {
-				var err error
-				kubeConfig, kubeInfo, err = k8s.ResolveKubeconfig(kubeconfigPath, kubeContext)
-				if err != nil {
-					return exit.New(1, err)
-				}
-				haveKube = true
-				if verbose {
-					fmt.Fprintln(os.Stderr, k8s.DescribeKubeconfig(kubeInfo))
-				}
+			if allClusters && cmd.Flags().Changed("rancher-cluster") {
+				return exit.New(1, fmt.Errorf("--all-clusters cannot be combined with --rancher-cluster"))
+			}
+			if !allClusters && (clusterFilter != "" || clusterSelRaw != "") {
+				return exit.New(1, fmt.Errorf("--cluster-filter and --cluster-selector require --all-clusters"))
+			}
+			if watch && (allClusters || multipleContexts()) {
+				return exit.New(1, fmt.Errorf("--watch cannot be combined with --all-clusters, --contexts or a repeated --context"))
+			}
+			if watch && mode == output.ModeYAML {
+				return exit.New(1, fmt.Errorf("--watch supports table or json (NDJSON) output"))
+			}
+			if watch && watchInterval <= 0 {
+				return exit.New(1, fmt.Errorf("--watch-interval must be positive"))
 			}
+			conn.allClusters = allClusters
+			conn.multiContext = true
 
-			if rancherURL == "" || rancherToken == "" {
-				if !haveKube {
-					return exit.New(1, fmt.Errorf("rancher URL or token missing and kubeconfig unavailable"))
+			filterNodes := func(nodes []types.K8sNode) ([]types.K8sNode, error) {
+				nodes = state.apply(cmd, nodes)
+				if labelSearch == "" {
+					return nodes, nil
 				}
-				server, token, err := k8s.ExtractServerAndToken(kubeConfig, kubeInfo.Context)
+				filtered, err := filterNodesByLabelPatterns(nodes, parseLabelKeys(labelSearch))
 				if err != nil {
-					return exit.New(1, err)
-				}
-				if rancherURL == "" {
-					derived, err := rancher.InventoryURLFromServer(server)
-					if err != nil {
-						return exit.New(1, err)
-					}
-					rancherURL = derived
-					if verbose {
-						fmt.Fprintf(os.Stderr, "rancher url from kubeconfig=%s\n", rancherURL)
-					}
+					return nil, err
 				}
-				if rancherToken == "" {
-					rancherToken = token
-					if verbose {
-						fmt.Fprintln(os.Stderr, "rancher token from kubeconfig")
-					}
+				if verbose {
+					fmt.Fprintf(os.Stderr, "label filter matched %d/%d nodes\n", len(filtered), len(nodes))
 				}
+				return filtered, nil
 			}
 
-			if rancherURL == "" {
-				return exit.New(1, fmt.Errorf("rancher URL is required (use --rancher-url or RANCHER_URL)"))
-			}
-			if rancherToken == "" {
-				return exit.New(1, fmt.Errorf("rancher token is required (use --rancher-token or RANCHER_TOKEN)"))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if err := conn.connect(ctx, cmd, true); err != nil {
+				return err
 			}
+			defer conn.finish()
+			session, cache := conn.session, conn.cache
 
-			rancherClient, err := rancher.NewClient(rancherURL, rancherToken, insecureTLS)
-			if err != nil {
-				return exit.New(1, err)
+			if watch {
+				return watchMatches(ctx, &conn, watchOptions{
+					interval:  watchInterval,
+					selector:  selectorParsed,
+					inventory: inventoryOpts,
+					filter:    filterNodes,
+					mode:      mode,
+				})
 			}
 
-			hostsCh := make(chan hostResult, 1)
+			var (
+				nodes       []types.K8sNode
+				clusterName string
+			)
+
+			hostPages := make(chan []types.InventoryHost, 16)
+			hostsErr := make(chan error, 1)
 			go func() {
-				hosts, err := rancherClient.ListInventoryHosts(ctx)
-				hostsCh <- hostResult{hosts: hosts, err: err}
+				defer close(hostPages)
+				hostsErr <- streamInventoryHosts(ctx, session, cache, inventoryOpts, func(hosts []types.InventoryHost) error {
+					select {
+					case hostPages <- hosts:
+						return nil
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
 			}()
 
 			var machinesCh chan machineResult
-			if rancherCluster != "" {
-				machinesURL, err := rancher.MachinesURLFromInventoryURL(rancherURL)
-				if err != nil {
-					return exit.New(1, err)
-				}
-				machinesClient, err := rancher.NewClient(machinesURL.String(), rancherToken, insecureTLS)
-				if err != nil {
-					return exit.New(1, err)
-				}
+			if conn.rancherNodes() {
 				machinesCh = make(chan machineResult, 1)
 				go func() {
-					machines, err := machinesClient.ListMachines(ctx)
+					machines, err := cachedList(cache, "machines", session.ResultCacheName("machines"), func() ([]rancher.Machine, error) {
+						return session.Machines().ListMachines(ctx)
+					})
 					machinesCh <- machineResult{machines: machines, err: err}
 				}()
 			}
 
-			if rancherCluster != "" {
-				managementURL, err := rancher.ClustersURLFromInventoryURL(rancherURL)
-				if err != nil {
-					return exit.New(1, err)
-				}
-				managementClient, err := rancher.NewClient(managementURL.String(), rancherToken, insecureTLS)
+			var (
+				clusterSummaries []match.ClusterSummary
+				clusterErrors    map[string]string
+			)
+			switch {
+			case allClusters:
+				clusters, err := conn.listClusters(ctx)
 				if err != nil {
-					return exit.New(1, err)
+					return exit.New(2, err)
 				}
-				cluster, err := managementClient.ResolveCluster(ctx, rancherCluster)
+				clusters, err = filterClusters(clusters, parseLabelKeys(clusterFilter), clusterSelector)
 				if err != nil {
 					return exit.New(1, err)
 				}
-				clusterName = cluster.Name
-				if clusterName == "" {
-					clusterName = cluster.ID
+				if len(clusters) == 0 {
+					return exit.New(1, fmt.Errorf("no Rancher clusters match the cluster filter"))
 				}
-				cacheKey := rancher.KubeconfigCacheKey(managementURL.String(), cluster.ID)
-				kubeconfigBytes, cacheAge, cacheHit, err := rancher.LoadCachedKubeconfig(cacheKey, rancher.DefaultKubeconfigCacheTTL)
-				if err != nil && verbose {
-					fmt.Fprintf(os.Stderr, "kubeconfig cache read failed: %v\n", err)
+				if verbose {
+					fmt.Fprintf(os.Stderr, "matching across %d clusters (parallel=%d)\n", len(clusters), parallel)
 				}
-				if cacheHit {
-					if verbose {
-						fmt.Fprintf(os.Stderr, "using cached kubeconfig age=%s\n", cacheAge.Truncate(time.Second))
-					}
-				} else {
-					kubeconfigBytes, err = managementClient.GenerateKubeconfig(ctx, cluster.ID)
-					if err != nil {
-						return exit.New(2, err)
+				var machines []rancher.Machine
+				if machinesCh != nil {
+					result := <-machinesCh
+					if result.err != nil {
+						if verbose {
+							fmt.Fprintf(os.Stderr, "rancher machine lookup skipped: %v\n", result.err)
+						}
+					} else {
+						machines = result.machines
 					}
-					if err := rancher.SaveCachedKubeconfig(cacheKey, kubeconfigBytes); err != nil && verbose {
-						fmt.Fprintf(os.Stderr, "kubeconfig cache write failed: %v\n", err)
+				}
+				clusterErrors = map[string]string{}
+				var firstErr error
+				for _, fetched := range fetchClustersNodes(ctx, session, cache, clusters, nodeQuery{selector: selectorParsed}, parallel) {
+					name := clusterDisplayName(fetched.cluster)
+					if fetched.err != nil {
+						if firstErr == nil {
+							firstErr = fetched.err
+						}
+						clusterErrors[name] = fetched.err.Error()
+						if verbose {
+							fmt.Fprintf(os.Stderr, "cluster %s skipped: %v\n", name, fetched.err)
+						}
+						continue
 					}
+					applyMachineNames(fetched.nodes, machines, fetched.cluster.Name)
+					nodes = append(nodes, fetched.nodes...)
 				}
-				kubeConfig, info, err := k8s.ResolveKubeconfigFromBytes(kubeconfigBytes, "rancher", []string{"cluster:" + cluster.ID}, kubeContext)
-				if err != nil {
-					return exit.New(1, err)
+				if len(clusterErrors) == len(clusters) {
+					return exit.AllFailed(firstErr)
 				}
+			case len(conn.contexts) > 0:
 				if verbose {
-					fmt.Fprintf(os.Stderr, "%s cluster=%s\n", k8s.DescribeKubeconfig(info), cluster.Name)
+					fmt.Fprintf(os.Stderr, "matching across %d contexts (parallel=%d)\n", len(conn.contexts), parallel)
 				}
-				client, err := k8s.NewClient(kubeConfig)
-				if err != nil {
-					return exit.New(1, err)
+				clusterErrors = map[string]string{}
+				var firstErr error
+				for _, fetched := range fetchContextsNodes(ctx, cache, conn.contexts, nodeQuery{selector: selectorParsed}, parallel) {
+					if fetched.err != nil {
+						if firstErr == nil {
+							firstErr = fetched.err
+						}
+						clusterErrors[fetched.context] = fetched.err.Error()
+						if verbose {
+							fmt.Fprintf(os.Stderr, "context %s skipped: %v\n", fetched.context, fetched.err)
+						}
+						continue
+					}
+					nodes = append(nodes, fetched.nodes...)
+				}
+				if len(clusterErrors) == len(conn.contexts) {
+					return exit.AllFailed(firstErr)
 				}
-				nodes, err = client.ListNodes(ctx, selectorParsed)
+			default:
+				var (
+					cluster rancher.Cluster
+					err     error
+				)
+				nodes, cluster, err = conn.listNodes(ctx, nodeQuery{selector: selectorParsed})
 				if err != nil {
-					return exit.New(2, err)
+					return err
+				}
+				if cluster.ID != "" {
+					clusterName = clusterDisplayName(cluster)
 				}
 				if machinesCh != nil {
 					result := <-machinesCh
@@ -208,74 +241,66 @@ func newMatchCmd() *cobra.Command {
 							fmt.Fprintf(os.Stderr, "rancher machine lookup skipped: %v\n", result.err)
 						}
 					} else {
-						nameByNode := rancher.MachineNameMap(result.machines, cluster.Name)
-						if len(nameByNode) > 0 {
-							for i := range nodes {
-								if name := nameByNode[nodes[i].Name]; name != "" {
-									nodes[i].MachineName = name
-								}
-							}
-						}
+						applyMachineNames(nodes, result.machines, cluster.Name)
 					}
 				}
-			} else {
-				if !haveKube {
-					return exit.New(1, fmt.Errorf("kubeconfig is required to list nodes"))
-				}
-				client, err := k8s.NewClient(kubeConfig)
-				if err != nil {
-					return exit.New(1, err)
-				}
-				nodes, err = client.ListNodes(ctx, selectorParsed)
-				if err != nil {
-					return exit.New(2, err)
-				}
 			}
-			if labelSearch != "" {
-				patterns := parseLabelKeys(labelSearch)
-				filtered, err := filterNodesByLabelPatterns(nodes, patterns)
-				if err != nil {
-					return exit.New(1, err)
-				}
-				if verbose {
-					fmt.Fprintf(os.Stderr, "label filter matched %d/%d nodes\n", len(filtered), len(nodes))
-				}
-				nodes = filtered
+			nodes, err = filterNodes(nodes)
+			if err != nil {
+				return exit.New(1, err)
 			}
-			hostResult := <-hostsCh
-			if hostResult.err != nil {
-				return exit.New(2, hostResult.err)
+			matcher := match.NewMatcher(nodes)
+			for hosts := range hostPages {
+				matcher.Add(hosts...)
+			}
+			if err := <-hostsErr; err != nil {
+				return exit.New(2, err)
 			}
-			hosts := hostResult.hosts
 
-			result := match.Match(hosts, nodes)
+			result := matcher.Result()
+			if allClusters || len(conn.contexts) > 0 {
+				clusterSummaries = match.SummarizeClusters(result, nodes)
+			}
 			opts := output.MatchOptions{
 				ShowUnmatched: showUnmatched,
 				Explain:       explain,
 				Wide:          wide,
 				Mode:          mode,
 				ClusterName:   clusterName,
+				Clusters:      clusterSummaries,
+				ClusterErrors: clusterErrors,
 			}
 			if err := output.RenderMatch(result, opts); err != nil {
 				return exit.New(1, err)
 			}
+			if len(clusterErrors) > 0 {
+				return exit.NewPartial("partial_results", fmt.Errorf("partial results: %d clusters or contexts failed", len(clusterErrors)), clusterErrors)
+			}
 			if len(result.Ambiguous) > 0 {
-				return exit.New(3, fmt.Errorf("ambiguous matches present"))
+				return exit.NewAmbiguous("ambiguous_matches", fmt.Errorf("ambiguous matches present"))
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVar(&rancherURL, "rancher-url", "", "Rancher inventory API URL")
-	cmd.Flags().StringVar(&rancherToken, "rancher-token", "", "Rancher API bearer token")
-	cmd.Flags().StringVar(&rancherCluster, "rancher-cluster", "", "downstream cluster name or ID (resolved via Rancher)")
+	conn.addFlags(cmd)
+	cmd.Flags().BoolVar(&allClusters, "all-clusters", false, "match against the nodes of every downstream cluster known to Rancher")
+	cmd.Flags().StringVar(&clusterFilter, "cluster-filter", "", "with --all-clusters, only include clusters whose name or ID matches (comma-separated, supports * or /regex/)")
+	cmd.Flags().StringVar(&clusterSelRaw, "cluster-selector", "", "with --all-clusters, only include clusters whose Rancher labels match this selector")
+	cmd.Flags().IntVar(&parallel, "parallel", 4, "maximum number of clusters or contexts fetched concurrently")
+	cmd.Flags().StringVar(&inventoryNS, "inventory-namespace", "", "only list MachineInventories in this namespace")
+	cmd.Flags().StringVar(&inventorySel, "inventory-selector", "", "label selector to filter MachineInventories")
 	cmd.Flags().StringVar(&labelSearch, "labels", "", "filter nodes by label key/value (comma-separated, supports * or /regex/)")
+	cmd.RegisterFlagCompletionFunc("labels", completeCommaList(conn.completeLabelKeys))
 	cmd.Flags().StringVar(&selectorRaw, "selector", "", "label selector to filter nodes")
+	state.addFlags(cmd)
 	cmd.Flags().BoolVar(&showUnmatched, "show-unmatched", false, "show unmatched hosts and nodes")
 	cmd.Flags().BoolVar(&explain, "explain", false, "include match explanations")
 	cmd.Flags().BoolVar(&wide, "wide", false, "show wide output")
+	cmd.Flags().BoolVar(&watch, "watch", false, "keep running and print match changes as nodes and inventory change")
+	cmd.Flags().DurationVar(&watchInterval, "watch-interval", 30*time.Second, "with --watch, how often the inventory is refreshed")
 	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
-	cmd.Flags().BoolVar(&insecureTLS, "insecure-skip-tls-verify", false, "skip TLS verification for Rancher")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
 
 	return cmd
 }
@@ -23,6 +23,8 @@ func newNodesCmd() *cobra.Command {
 		labelKeys   string
 		wide        bool
 		outputMode  string
+		conn        connector
+		state       nodeStateFilter
 	)
 
 	cmd := &cobra.Command{
@@ -39,24 +41,17 @@ func newNodesCmd() *cobra.Command {
 				return exit.New(1, err)
 			}
 
-			kubeConfig, info, err := k8s.ResolveKubeconfig(kubeconfigPath, kubeContext)
-			if err != nil {
-				return exit.New(1, err)
-			}
-			if verbose {
-				fmt.Fprintln(os.Stderr, k8s.DescribeKubeconfig(info))
-			}
-
-			client, err := k8s.NewClient(kubeConfig)
-			if err != nil {
-				return exit.New(1, err)
+			ctx := context.Background()
+			if err := conn.connect(ctx, cmd, false); err != nil {
+				return err
 			}
+			defer conn.finish()
 
-			ctx := context.Background()
-			nodes, err := client.ListNodes(ctx, selectorParsed)
+			nodes, _, err := conn.listNodes(ctx, nodeQuery{selector: selectorParsed})
 			if err != nil {
-				return exit.New(2, err)
+				return err
 			}
+			nodes = state.apply(cmd, nodes)
 
 			keys := parseLabelKeys(labelKeys)
 			if len(keys) > 0 {
@@ -82,12 +77,48 @@ func newNodesCmd() *cobra.Command {
 	cmd.Flags().StringVar(&selectorRaw, "selector", "", "label selector to filter nodes")
 	cmd.Flags().BoolVar(&showLabels, "labels", false, "show all labels in output")
 	cmd.Flags().StringVar(&labelKeys, "label-keys", "", "comma-separated label keys or patterns (exact key, * wildcard, or /regex/)")
+	cmd.RegisterFlagCompletionFunc("label-keys", completeCommaList(conn.completeLabelKeys))
 	cmd.Flags().BoolVar(&wide, "wide", false, "show wide output")
+	state.addFlags(cmd)
+	conn.addFlags(cmd)
 	cmd.Flags().StringVar(&outputMode, "output", "table", "output format: table|json|yaml")
+	cmd.RegisterFlagCompletionFunc("output", completeOutputModes)
 
 	return cmd
 }
 
+type nodeStateFilter struct {
+	ready bool
+	roles string
+}
+
+func (f *nodeStateFilter) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.ready, "ready", false, "only keep Ready nodes (--ready=false keeps nodes that are not Ready)")
+	cmd.Flags().StringVar(&f.roles, "role", "", "only keep nodes with one of these roles (comma-separated, e.g. worker,control-plane)")
+}
+
+func (f *nodeStateFilter) apply(cmd *cobra.Command, nodes []types.K8sNode) []types.K8sNode {
+	filterReady := cmd.Flags().Changed("ready")
+	roles := parseLabelKeys(f.roles)
+	if !filterReady && len(roles) == 0 {
+		return nodes
+	}
+	filtered := make([]types.K8sNode, 0, len(nodes))
+	for _, node := range nodes {
+		if filterReady && node.Ready != f.ready {
+			continue
+		}
+		if len(roles) > 0 && !k8s.NodeHasRole(node, roles) {
+			continue
+		}
+		filtered = append(filtered, node)
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "state filter matched %d/%d nodes\n", len(filtered), len(nodes))
+	}
+	return filtered
+}
+
 func parseLabelKeys(raw string) []string {
 	if raw == "" {
 		return nil
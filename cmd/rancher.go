@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/spf13/cobra"
+)
+
+type rancherOptions struct {
+	url          string
+	token        string
+	tokenFile    string
+	tokenCommand string
+	insecureTLS  bool
+	caFile       string
+	clientCert   string
+	clientKey    string
+	proxy        string
+	retries      int
+	timeout      time.Duration
+	pageSize     int
+}
+
+func (o *rancherOptions) addFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&o.url, "rancher-url", "", "Rancher server URL (legacy inventory collection URLs are also accepted)")
+	flags.BoolVar(&o.insecureTLS, "insecure-skip-tls-verify", false, "skip TLS verification for Rancher")
+	flags.StringVar(&o.caFile, "rancher-ca-file", "", "PEM CA bundle to trust for Rancher")
+	flags.StringVar(&o.clientCert, "rancher-client-cert", "", "client certificate for mutual TLS with Rancher")
+	flags.StringVar(&o.clientKey, "rancher-client-key", "", "client key for mutual TLS with Rancher")
+	flags.IntVar(&o.retries, "rancher-retries", rancher.DefaultRetryPolicy.Attempts, "attempts per Rancher request (429, 5xx and network errors are retried)")
+	flags.DurationVar(&o.timeout, "rancher-timeout", 20*time.Second, "timeout for each Rancher request")
+	flags.IntVar(&o.pageSize, "rancher-page-size", rancher.DefaultPageSize, "items requested per Rancher list page")
+	flags.StringVar(&o.proxy, "rancher-proxy", "", "proxy URL for Rancher (defaults to HTTPS_PROXY/NO_PROXY)")
+}
+
+func (o *rancherOptions) addTokenFlags(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.StringVar(&o.token, "rancher-token", "", "Rancher API bearer token")
+	flags.StringVar(&o.tokenFile, "rancher-token-file", "", "read the Rancher token from a file")
+	flags.StringVar(&o.tokenCommand, "rancher-token-command", "", `command printing {"token": ..., "expiresAt": ...} as JSON`)
+}
+
+func (o *rancherOptions) applyEnv(cmd *cobra.Command) {
+	o.url = firstNonEmpty(o.url, os.Getenv("RANCHER_URL"))
+	// An explicit token source flag wins over token env vars.
+	if o.tokenFile == "" && o.tokenCommand == "" {
+		o.token = firstNonEmpty(o.token, os.Getenv("RANCHER_TOKEN"))
+	}
+	if o.token == "" && o.tokenFile == "" && o.tokenCommand == "" {
+		o.tokenFile = os.Getenv("RANCHER_TOKEN_FILE")
+		o.tokenCommand = os.Getenv("RANCHER_TOKEN_COMMAND")
+	}
+	o.caFile = firstNonEmpty(o.caFile, os.Getenv("RANCHER_CA_FILE"))
+	o.clientCert = firstNonEmpty(o.clientCert, os.Getenv("RANCHER_CLIENT_CERT"))
+	o.clientKey = firstNonEmpty(o.clientKey, os.Getenv("RANCHER_CLIENT_KEY"))
+	o.proxy = firstNonEmpty(o.proxy, os.Getenv("RANCHER_PROXY"))
+	if !cmd.Flags().Changed("insecure-skip-tls-verify") {
+		if env := os.Getenv("RANCHER_INSECURE_SKIP_TLS_VERIFY"); env == "true" || env == "1" {
+			o.insecureTLS = true
+		}
+	}
+}
+
+func (o *rancherOptions) sessionOptions() rancher.SessionOptions {
+	opts := rancher.SessionOptions{
+		InsecureSkipVerify: o.insecureTLS,
+		CAFile:             o.caFile,
+		CertFile:           o.clientCert,
+		KeyFile:            o.clientKey,
+		ProxyURL:           o.proxy,
+		Timeout:            o.timeout,
+		Retry:              rancher.RetryPolicy{Attempts: o.retries},
+		PageSize:           o.pageSize,
+		Impersonate:        impersonation(),
+	}
+	if verbose {
+		opts.OnRetry = func(event rancher.RetryEvent) {
+			fmt.Fprintf(os.Stderr, "rancher retry %d/%d in %s endpoint=%s: %v\n", event.Attempt, event.Attempts-1, event.Wait.Truncate(time.Millisecond), event.Endpoint, event.Err)
+		}
+	}
+	return opts
+}
+
+// resolveToken reads the token from --rancher-token-file or
+// --rancher-token-command when no token was given directly.
+// With cachedOnly the command is not run and only a cached token is used.
+func (o *rancherOptions) resolveToken(ctx context.Context, cachedOnly bool) error {
+	if o.token != "" {
+		return nil
+	}
+	if o.tokenFile != "" && o.tokenCommand != "" {
+		return fmt.Errorf("--rancher-token-file and --rancher-token-command are mutually exclusive")
+	}
+	switch {
+	case o.tokenFile != "":
+		token, err := rancher.ReadTokenFile(o.tokenFile)
+		if err != nil {
+			return err
+		}
+		o.token = token
+		if verbose {
+			fmt.Fprintf(os.Stderr, "rancher token from file=%s\n", o.tokenFile)
+		}
+	case o.tokenCommand != "" && cachedOnly:
+		token, ok := rancher.CachedCommandToken(o.tokenCommand, o.url)
+		if !ok {
+			return fmt.Errorf("no cached token from --rancher-token-command; run a command once first")
+		}
+		o.token = token
+	case o.tokenCommand != "":
+		token, err := rancher.TokenFromCommand(ctx, o.tokenCommand, o.url)
+		if err != nil {
+			return err
+		}
+		o.token = token
+		if verbose {
+			fmt.Fprintln(os.Stderr, "rancher token from command")
+		}
+	}
+	return nil
+}
+
+const tokenExpiryWarning = 24 * time.Hour
+
+// applyStoredToken falls back to the token saved by `login` for the Rancher URL.
+func (o *rancherOptions) applyStoredToken() {
+	if o.token != "" || o.url == "" {
+		return
+	}
+	stored, ok, err := rancher.LoadStoredToken(o.url)
+	if err != nil {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "stored rancher token unavailable: %v\n", err)
+		}
+		return
+	}
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if stored.Expired(now) {
+		fmt.Fprintf(os.Stderr, "warning: stored rancher token for %s expired at %s; run `elemental-node-map login` again\n", stored.RancherURL, stored.ExpiresAt.Format(time.RFC3339))
+		return
+	}
+	if stored.ExpiresWithin(tokenExpiryWarning, now) {
+		fmt.Fprintf(os.Stderr, "warning: stored rancher token for %s expires at %s\n", stored.RancherURL, stored.ExpiresAt.Format(time.RFC3339))
+	}
+	o.token = stored.Token
+	if verbose {
+		fmt.Fprintln(os.Stderr, "rancher token from login store")
+	}
+}
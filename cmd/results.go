@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// cachedList returns the cached result stored under name unless --refresh is
+// set or it is older than --result-ttl; otherwise it calls fetch and stores
+// what it returns.
+func cachedList[T any](cache *rancher.Cache, what, name string, fetch func() ([]T, error)) ([]T, error) {
+	if cache != nil && !refresh {
+		var cached []T
+		age, ok, err := cache.LoadResult(name, resultTTL, &cached)
+		if err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "result cache read failed: %v\n", err)
+		}
+		if ok {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "using cached %s age=%s\n", what, age.Truncate(time.Second))
+			}
+			return cached, nil
+		}
+	}
+	items, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		if err := cache.SaveResult(name, items); err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "result cache write failed: %v\n", err)
+		}
+	}
+	return items, nil
+}
+
+// streamInventoryHosts replays cached inventory hosts as a single page, or
+// streams them from Rancher and caches the full listing once it completes.
+func streamInventoryHosts(ctx context.Context, session *rancher.Session, cache *rancher.Cache, opts rancher.InventoryOptions, fn func([]types.InventoryHost) error) error {
+	selectorText := ""
+	if opts.Selector != nil {
+		selectorText = opts.Selector.String()
+	}
+	name := session.ResultCacheName("inventories", opts.Namespace, selectorText)
+	if cache != nil && !refresh {
+		var hosts []types.InventoryHost
+		age, ok, err := cache.LoadResult(name, resultTTL, &hosts)
+		if err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "result cache read failed: %v\n", err)
+		}
+		if ok {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "using cached inventory hosts age=%s\n", age.Truncate(time.Second))
+			}
+			return fn(hosts)
+		}
+	}
+
+	var all []types.InventoryHost
+	err := session.Inventories().StreamInventoryHosts(ctx, opts, func(hosts []types.InventoryHost) error {
+		if cache != nil {
+			all = append(all, hosts...)
+		}
+		return fn(hosts)
+	})
+	if err != nil {
+		return err
+	}
+	if cache != nil {
+		if err := cache.SaveResult(name, all); err != nil && verbose {
+			fmt.Fprintf(os.Stderr, "result cache write failed: %v\n", err)
+		}
+	}
+	return nil
+}
+
+// listKubeconfigNodes lists the nodes of the cluster behind a local kubeconfig.
+func listKubeconfigNodes(ctx context.Context, cache *rancher.Cache, kubeConfig clientcmd.ClientConfig, info k8s.KubeconfigInfo, query nodeQuery) ([]types.K8sNode, error) {
+	name := rancher.ResultCacheName(append([]string{"nodes"}, kubeconfigIdentity(kubeConfig, info, query)...)...)
+	return cachedList(cache, "nodes", name, func() ([]types.K8sNode, error) {
+		return query.list(ctx, kubeConfig)
+	})
+}
+
+// nodeQuery describes a node listing. metadataOnly lists PartialObjectMetadata
+// for commands that only need names and labels; cachedOnly, used while
+// completing, never generates a kubeconfig or lets exec plugins prompt.
+type nodeQuery struct {
+	selector     labels.Selector
+	metadataOnly bool
+	cachedOnly   bool
+}
+
+func (q nodeQuery) cacheKey() []string {
+	view := "full"
+	if q.metadataOnly {
+		view = "metadata"
+	}
+	return []string{selectorString(q.selector), view}
+}
+
+func (q nodeQuery) list(ctx context.Context, kubeConfig clientcmd.ClientConfig) ([]types.K8sNode, error) {
+	opts := kubeOptions
+	opts.NonInteractiveExec = q.cachedOnly
+	client, err := k8s.NewClient(kubeConfig, opts)
+	if err != nil {
+		return nil, exit.New(1, err)
+	}
+	var nodes []types.K8sNode
+	if q.metadataOnly {
+		nodes, err = client.ListNodeMetadata(ctx, q.selector)
+	} else {
+		nodes, err = client.ListNodes(ctx, q.selector)
+	}
+	if err != nil {
+		return nil, exit.New(2, err)
+	}
+	return nodes, nil
+}
+
+func kubeconfigIdentity(kubeConfig clientcmd.ClientConfig, info k8s.KubeconfigInfo, query nodeQuery) []string {
+	parts := append([]string{info.Context}, query.cacheKey()...)
+	if restConfig, err := kubeConfig.ClientConfig(); err == nil {
+		parts = append(parts, restConfig.Host, restConfig.Username, restConfig.BearerToken, restConfig.CertFile, string(restConfig.CertData))
+		// Covers --as and the kubeconfig's own as/as-groups.
+		if as := restConfig.Impersonate; as.UserName != "" {
+			parts = append(append(parts, "as", as.UserName, as.UID), as.Groups...)
+		}
+	}
+	return parts
+}
+
+func selectorString(selectorParsed labels.Selector) string {
+	if selectorParsed == nil {
+		return ""
+	}
+	return selectorParsed.String()
+}
@@ -1,30 +1,151 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
 )
 
 var (
 	kubeconfigPath string
 	kubeContext    string
+	kubeContexts   []string
+	contextGlob    string
+	endpointRaw    string
+	endpointMode   k8s.EndpointMode
+	profileName    string
 	verbose        bool
+	cacheTTL       time.Duration
+	noCache        bool
+	refresh        bool
+	resultTTL      time.Duration
+	kubeOptions    k8s.ClientOptions
+	// impersonate holds --as, --as-group and --as-uid.
+	impersonate rest.ImpersonationConfig
 )
 
 func NewRootCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:           "elemental-node-map",
-		Short:         "Match Elemental inventory hosts with Kubernetes nodes",
-		SilenceUsage:  true,
-		SilenceErrors: true,
+		Use:               "elemental-node-map",
+		Short:             "Match Elemental inventory hosts with Kubernetes nodes",
+		SilenceUsage:      true,
+		SilenceErrors:     true,
+		PersistentPreRunE: preRun,
 	}
 
+	cmd.PersistentFlags().StringVar(&profileName, "profile", "", "config file profile to use (default: the current profile; env ELEMENTAL_NODE_MAP_PROFILE)")
 	cmd.PersistentFlags().StringVar(&kubeconfigPath, "kubeconfig", "", "path to kubeconfig file")
-	cmd.PersistentFlags().StringVar(&kubeContext, "context", "", "kubeconfig context to use")
+	cmd.PersistentFlags().Var(newUniqueArrayValue(&kubeContexts), "context", "kubeconfig context to use (match accepts it repeatedly to combine contexts)")
+	cmd.PersistentFlags().StringVar(&contextGlob, "contexts", "", "with match, combine every kubeconfig context matching this glob (e.g. 'prod-*')")
+	cmd.PersistentFlags().StringVar(&endpointRaw, "endpoint", string(k8s.EndpointProxy), "how Rancher-generated kubeconfigs reach downstream clusters: proxy|direct|auto (auto tries authorized cluster endpoints, then the proxy)")
 	cmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "enable verbose logging")
+	cmd.PersistentFlags().DurationVar(&cacheTTL, "cache-ttl", rancher.DefaultKubeconfigCacheTTL, "how long Rancher-generated kubeconfigs are reused (0 keeps them until their token expires)")
+	cmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "do not read or write the on-disk cache")
+	cmd.PersistentFlags().BoolVar(&refresh, "refresh", false, "ignore cached inventory, machine, cluster and node listings (fresh results are still cached)")
+	cmd.PersistentFlags().DurationVar(&resultTTL, "result-ttl", rancher.DefaultResultCacheTTL, "how long inventory, machine, cluster and node listings are reused (0 disables)")
+	cmd.PersistentFlags().Float32Var(&kubeOptions.QPS, "kube-qps", k8s.DefaultQPS, "maximum queries per second to the Kubernetes API")
+	cmd.PersistentFlags().IntVar(&kubeOptions.Burst, "kube-burst", k8s.DefaultBurst, "burst allowance above --kube-qps")
+	cmd.PersistentFlags().DurationVar(&kubeOptions.Timeout, "kube-timeout", k8s.DefaultTimeout, "timeout for each Kubernetes API request")
+	cmd.PersistentFlags().Int64Var(&kubeOptions.PageSize, "kube-page-size", k8s.DefaultPageSize, "nodes requested per Kubernetes list page (0 uses the default)")
+	cmd.PersistentFlags().StringVar(&impersonate.UserName, "as", "", "username to impersonate on the Kubernetes API and Rancher's Steve API")
+	cmd.PersistentFlags().Var(newUniqueArrayValue(&impersonate.Groups), "as-group", "group to impersonate (repeatable, requires --as)")
+	cmd.PersistentFlags().StringVar(&impersonate.UID, "as-uid", "", "UID to impersonate (requires --as)")
+	addErrorFlags(cmd)
+	cmd.RegisterFlagCompletionFunc("context", completeContexts)
+	cmd.RegisterFlagCompletionFunc("endpoint", completeEndpointModes)
 
 	cmd.AddCommand(newMatchCmd())
 	cmd.AddCommand(newNodesCmd())
 	cmd.AddCommand(newLabelsCmd())
+	cmd.AddCommand(newLoginCmd())
+	cmd.AddCommand(newCacheCmd())
+	cmd.AddCommand(newDoctorCmd())
+	cmd.AddCommand(newKubeconfigCmd())
+	cmd.AddCommand(newConfigCmd())
 
 	return cmd
 }
+
+// preRun applies the profile and validates the global flags. Completion
+// functions call it too, as cobra skips the hooks for __complete.
+func preRun(cmd *cobra.Command, args []string) error {
+	if err := applyProfile(cmd); err != nil {
+		return err
+	}
+	if err := validateErrorFlags(cmd); err != nil {
+		return err
+	}
+	mode, err := k8s.ParseEndpointMode(endpointRaw)
+	if err != nil {
+		return exit.New(1, err)
+	}
+	endpointMode = mode
+	if len(kubeContexts) == 1 {
+		kubeContext = kubeContexts[0]
+	}
+	return validateImpersonation()
+}
+
+func validateImpersonation() error {
+	as := impersonate
+	if as.UserName == "" {
+		if len(as.Groups) > 0 || as.UID != "" {
+			return exit.New(1, fmt.Errorf("--as-group and --as-uid require --as"))
+		}
+		return nil
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "impersonating user=%s groups=%s uid=%s\n", as.UserName, strings.Join(as.Groups, ","), as.UID)
+	}
+	return nil
+}
+
+// impersonation is the --as identity in the form the Rancher session takes.
+func impersonation() rancher.Impersonation {
+	as := impersonate
+	return rancher.Impersonation{User: as.UserName, Groups: as.Groups, UID: as.UID}
+}
+
+// multipleContexts reports whether nodes should come from several kubeconfig
+// contexts rather than one.
+func multipleContexts() bool {
+	return len(kubeContexts) > 1 || contextGlob != ""
+}
+
+// uniqueArrayValue is a repeatable string flag that keeps each value once.
+// cobra parses the flags twice before running a completion function, which
+// would otherwise repeat every value.
+type uniqueArrayValue struct {
+	values *[]string
+}
+
+func newUniqueArrayValue(values *[]string) *uniqueArrayValue {
+	return &uniqueArrayValue{values: values}
+}
+
+func (v *uniqueArrayValue) Set(value string) error {
+	if !slices.Contains(*v.values, value) {
+		*v.values = append(*v.values, value)
+	}
+	return nil
+}
+
+func (v *uniqueArrayValue) Type() string {
+	return "stringArray"
+}
+
+// String is empty when unset so help does not print a default.
+func (v *uniqueArrayValue) String() string {
+	if len(*v.values) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(*v.values, ",") + "]"
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/exit"
+	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
+	"github.com/goldyfruit/elemental-node-mapper/internal/match"
+	"github.com/goldyfruit/elemental-node-mapper/internal/output"
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+type watchOptions struct {
+	interval  time.Duration
+	selector  labels.Selector
+	inventory rancher.InventoryOptions
+	// filter applies the node filters of match on every re-match.
+	filter func([]types.K8sNode) ([]types.K8sNode, error)
+	mode   output.Mode
+}
+
+// watchMatches follows the downstream nodes with an informer and the
+// inventory (and Rancher machines) through a Steve subscription, resyncs the
+// inventory every interval, and prints how the match result changes until
+// interrupted. The first batch of events describes the initial state.
+func watchMatches(ctx context.Context, conn *connector, opts watchOptions) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	kubeConfig, cluster, err := conn.downstreamKubeconfig(ctx)
+	if err != nil {
+		return err
+	}
+	client, err := k8s.NewClient(kubeConfig, kubeOptions)
+	if err != nil {
+		return exit.New(1, err)
+	}
+	watch, err := client.WatchNodes(ctx, opts.selector, func(err error) {
+		if verbose {
+			fmt.Fprintf(os.Stderr, "node watch error, retrying: %v\n", err)
+		}
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return exit.New(2, err)
+	}
+
+	session := conn.session
+	hosts, err := session.Inventories().ListInventoryHosts(ctx, opts.inventory)
+	if err != nil {
+		return exit.New(2, err)
+	}
+	var machines []rancher.Machine
+	refreshMachines := func() {
+		if !conn.rancherNodes() {
+			return
+		}
+		fetched, err := session.Machines().ListMachines(ctx)
+		if err != nil {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "rancher machine lookup skipped: %v\n", err)
+			}
+			return
+		}
+		machines = fetched
+	}
+	refreshMachines()
+
+	resourceTypes := []string{rancher.InventorySchemaID}
+	if conn.rancherNodes() {
+		resourceTypes = append(resourceTypes, rancher.MachineSchemaID)
+	}
+	sub := session.Subscribe(ctx, rancher.SubscribeOptions{
+		ResourceTypes: resourceTypes,
+		OnReconnect: func(attempt int, wait time.Duration, err error) {
+			if verbose {
+				fmt.Fprintf(os.Stderr, "rancher subscription reconnect attempt=%d wait=%s: %v\n", attempt, wait, err)
+			}
+		},
+	})
+	subEvents := sub.Events()
+
+	// Node, machine and resync changes rebuild the matcher; inventory events
+	// only re-match the host they concern.
+	var (
+		prev    match.Result
+		matcher *match.Matcher
+	)
+	rebuild := func() error {
+		nodes, err := watch.Nodes()
+		if err != nil {
+			return exit.New(2, err)
+		}
+		if cluster.ID != "" {
+			applyMachineNames(nodes, machines, cluster.Name)
+			tagNodesWithCluster(nodes, clusterDisplayName(cluster))
+		}
+		nodes, err = opts.filter(nodes)
+		if err != nil {
+			return exit.New(1, err)
+		}
+		matcher = match.NewMatcher(nodes)
+		matcher.Add(hosts...)
+		return nil
+	}
+	emit := func() error {
+		result := matcher.Result()
+		events := match.Diff(prev, result)
+		prev = result
+		if err := output.RenderWatchEvents(events, time.Now(), opts.mode); err != nil {
+			return exit.New(1, err)
+		}
+		return nil
+	}
+	if err := rebuild(); err != nil {
+		return err
+	}
+	if err := emit(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-watch.Changes():
+			if err := rebuild(); err != nil {
+				return err
+			}
+		case event, ok := <-subEvents:
+			if !ok {
+				// Keep going on the periodic resync alone.
+				subEvents = nil
+				if verbose && sub.Err() != nil {
+					fmt.Fprintf(os.Stderr, "rancher subscription ended, polling every %s: %v\n", opts.interval, sub.Err())
+				}
+				continue
+			}
+			switch event.ResourceType {
+			case rancher.InventorySchemaID:
+				host := event.Host()
+				keep := event.Type != rancher.ResourceRemoved && opts.inventory.Matches(host)
+				hosts = applyHostEvent(hosts, host, keep)
+				matcher.Remove(host)
+				if keep {
+					matcher.Add(host)
+				}
+			case rancher.MachineSchemaID:
+				machines = applyMachineEvent(machines, event)
+				if err := rebuild(); err != nil {
+					return err
+				}
+			default:
+				continue
+			}
+		case <-ticker.C:
+			fetched, err := session.Inventories().ListInventoryHosts(ctx, opts.inventory)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				if verbose {
+					fmt.Fprintf(os.Stderr, "inventory refresh failed, keeping previous hosts: %v\n", err)
+				}
+				continue
+			}
+			hosts = fetched
+			refreshMachines()
+			if err := rebuild(); err != nil {
+				return err
+			}
+		}
+		if err := emit(); err != nil {
+			return err
+		}
+	}
+}
+
+// applyHostEvent replaces host in hosts, or drops it when keep is false.
+func applyHostEvent(hosts []types.InventoryHost, host types.InventoryHost, keep bool) []types.InventoryHost {
+	out := make([]types.InventoryHost, 0, len(hosts)+1)
+	for _, existing := range hosts {
+		if existing.ID != host.ID {
+			out = append(out, existing)
+		}
+	}
+	if keep {
+		out = append(out, host)
+	}
+	return out
+}
+
+func applyMachineEvent(machines []rancher.Machine, event rancher.ResourceEvent) []rancher.Machine {
+	machine := event.Machine()
+	out := make([]rancher.Machine, 0, len(machines)+1)
+	for _, existing := range machines {
+		if existing.ID != machine.ID {
+			out = append(out, existing)
+		}
+	}
+	if event.Type != rancher.ResourceRemoved {
+		out = append(out, machine)
+	}
+	return out
+}
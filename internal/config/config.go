@@ -0,0 +1,244 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/fileutil"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PathEnv overrides the config file location.
+	PathEnv = "ELEMENTAL_NODE_MAP_CONFIG"
+	// ProfileEnv selects a profile when --profile is not given.
+	ProfileEnv = "ELEMENTAL_NODE_MAP_PROFILE"
+)
+
+type Kind int
+
+const (
+	KindString Kind = iota
+	KindBool
+	KindInt
+)
+
+// Setting is a profile key. Keys are named after the flag they default; Env
+// lists the variables and Flags the other flags that take precedence over
+// the profile value.
+type Setting struct {
+	Key    string
+	Env    []string
+	Flags  []string
+	Kind   Kind
+	Secret bool
+}
+
+// The token sources are alternatives: any one of them on the command line
+// or in the environment overrides all of them.
+var (
+	tokenEnv   = []string{"RANCHER_TOKEN", "RANCHER_TOKEN_FILE", "RANCHER_TOKEN_COMMAND"}
+	tokenFlags = []string{"rancher-token", "rancher-token-file", "rancher-token-command"}
+)
+
+var Settings = []Setting{
+	{Key: "rancher-url", Env: []string{"RANCHER_URL"}},
+	{Key: "rancher-token", Env: tokenEnv, Flags: tokenFlags, Secret: true},
+	{Key: "rancher-token-file", Env: tokenEnv, Flags: tokenFlags},
+	{Key: "rancher-token-command", Env: tokenEnv, Flags: tokenFlags},
+	{Key: "rancher-cluster", Env: []string{"RANCHER_CLUSTER"}},
+	{Key: "insecure-skip-tls-verify", Env: []string{"RANCHER_INSECURE_SKIP_TLS_VERIFY"}, Kind: KindBool},
+	{Key: "rancher-ca-file", Env: []string{"RANCHER_CA_FILE"}},
+	{Key: "rancher-client-cert", Env: []string{"RANCHER_CLIENT_CERT"}},
+	{Key: "rancher-client-key", Env: []string{"RANCHER_CLIENT_KEY"}},
+	{Key: "rancher-proxy", Env: []string{"RANCHER_PROXY"}},
+	{Key: "kubeconfig", Env: []string{"KUBECONFIG"}},
+	{Key: "context", Flags: []string{"contexts"}},
+	{Key: "endpoint"},
+	{Key: "output"},
+	{Key: "error-format"},
+	{Key: "show-unmatched", Kind: KindBool},
+	{Key: "explain", Kind: KindBool},
+	{Key: "wide", Kind: KindBool},
+	{Key: "parallel", Kind: KindInt},
+	{Key: "inventory-namespace"},
+	{Key: "inventory-selector"},
+}
+
+func Lookup(key string) (Setting, bool) {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, true
+		}
+	}
+	return Setting{}, false
+}
+
+// Profile maps setting keys to values.
+type Profile map[string]string
+
+type File struct {
+	CurrentProfile string             `yaml:"current-profile,omitempty" json:"currentProfile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty" json:"profiles,omitempty"`
+}
+
+// DefaultPath is <user config dir>/elemental-node-map/config.yaml unless
+// PathEnv is set.
+func DefaultPath() (string, error) {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config dir: %w", err)
+	}
+	return filepath.Join(base, "elemental-node-map", "config.yaml"), nil
+}
+
+// Load reads path; a missing file is an empty config.
+func Load(path string) (*File, error) {
+	file := &File{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	for name, profile := range file.Profiles {
+		for key := range profile {
+			if _, ok := Lookup(key); !ok {
+				return nil, fmt.Errorf("invalid config %s: profile %q has unknown setting %q", path, name, key)
+			}
+		}
+	}
+	return file, nil
+}
+
+// Save writes the config atomically with owner-only permissions, since
+// profiles may hold tokens.
+func (f *File) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteFileAtomic(path, data)
+}
+
+// Update loads path, applies fn and saves the result while holding a lock on
+// its directory, so concurrent edits do not lose each other's changes.
+func Update(path string, fn func(*File) error) error {
+	return fileutil.WithDirLock(filepath.Dir(path), true, func() error {
+		file, err := Load(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(file); err != nil {
+			return err
+		}
+		return file.Save(path)
+	})
+}
+
+// Profile returns the named profile, or the current one when name is empty.
+// No name and no current profile yields an empty profile.
+func (f *File) Profile(name string) (string, Profile, error) {
+	if name == "" {
+		name = f.CurrentProfile
+	}
+	if name == "" {
+		return "", nil, nil
+	}
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return name, nil, fmt.Errorf("profile %q not found (known: %s)", name, f.profileNames())
+	}
+	return name, profile, nil
+}
+
+// Set stores value under key in the named profile, creating the profile if
+// needed; an empty value removes the key.
+func (f *File) Set(profile, key, value string) error {
+	setting, ok := Lookup(key)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	if value != "" {
+		if err := setting.validate(value); err != nil {
+			return err
+		}
+	}
+	if f.Profiles == nil {
+		f.Profiles = map[string]Profile{}
+	}
+	values := f.Profiles[profile]
+	if values == nil {
+		values = Profile{}
+		f.Profiles[profile] = values
+	}
+	if value == "" {
+		delete(values, key)
+		return nil
+	}
+	values[key] = value
+	return nil
+}
+
+func (f *File) UseProfile(name string) error {
+	if _, ok := f.Profiles[name]; !ok {
+		return fmt.Errorf("profile %q not found (known: %s)", name, f.profileNames())
+	}
+	f.CurrentProfile = name
+	return nil
+}
+
+// Redacted returns a copy with secret values masked, for display.
+func (f *File) Redacted() *File {
+	out := &File{CurrentProfile: f.CurrentProfile}
+	if len(f.Profiles) > 0 {
+		out.Profiles = make(map[string]Profile, len(f.Profiles))
+	}
+	for name, profile := range f.Profiles {
+		values := make(Profile, len(profile))
+		for key, value := range profile {
+			if setting, ok := Lookup(key); ok && setting.Secret {
+				value = "REDACTED"
+			}
+			values[key] = value
+		}
+		out.Profiles[name] = values
+	}
+	return out
+}
+
+func (f *File) profileNames() string {
+	if len(f.Profiles) == 0 {
+		return "none"
+	}
+	return strings.Join(slices.Sorted(maps.Keys(f.Profiles)), ", ")
+}
+
+func (s Setting) validate(value string) error {
+	switch s.Kind {
+	case KindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false", s.Key)
+		}
+	case KindInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be an integer", s.Key)
+		}
+	}
+	return nil
+}
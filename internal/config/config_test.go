@@ -0,0 +1,141 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadMissingFile(t *testing.T) {
+	file, err := Load(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, profile, err := file.Profile("")
+	if err != nil || name != "" || profile != nil {
+		t.Fatalf("expected no profile, got %q %v (%v)", name, profile, err)
+	}
+}
+
+func TestSetSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "config.yaml")
+	file := &File{}
+	if err := file.Set("prod-mtl", "rancher-url", "https://rancher.example.com"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := file.Set("prod-mtl", "rancher-token", "token-abc:secret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := file.Set("prod-mtl", "show-unmatched", "true"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := file.UseProfile("prod-mtl"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := file.Save(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("expected mode 0600, got %o", perm)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	name, profile, err := loaded.Profile("")
+	if err != nil || name != "prod-mtl" {
+		t.Fatalf("expected current profile prod-mtl, got %q (%v)", name, err)
+	}
+	if profile["rancher-url"] != "https://rancher.example.com" || profile["show-unmatched"] != "true" {
+		t.Fatalf("unexpected profile %v", profile)
+	}
+	if got := loaded.Redacted().Profiles["prod-mtl"]["rancher-token"]; got != "REDACTED" {
+		t.Fatalf("expected redacted token, got %q", got)
+	}
+	if profile["rancher-token"] != "token-abc:secret" {
+		t.Fatalf("redaction must not modify the loaded config")
+	}
+
+	if err := loaded.Set("prod-mtl", "rancher-token", ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := loaded.Profiles["prod-mtl"]["rancher-token"]; ok {
+		t.Fatalf("expected empty value to remove the key")
+	}
+}
+
+func TestSetRejectsUnknownOrInvalid(t *testing.T) {
+	file := &File{}
+	if err := file.Set("dev", "rancher-password", "x"); err == nil {
+		t.Fatalf("expected error for unknown key")
+	}
+	if err := file.Set("dev", "wide", "sometimes"); err == nil {
+		t.Fatalf("expected error for invalid bool")
+	}
+	if err := file.Set("dev", "parallel", "many"); err == nil {
+		t.Fatalf("expected error for invalid int")
+	}
+}
+
+func TestProfileErrors(t *testing.T) {
+	file := &File{Profiles: map[string]Profile{"dev": {}, "prod": {}}}
+	if _, _, err := file.Profile("staging"); err == nil || !strings.Contains(err.Error(), "dev, prod") {
+		t.Fatalf("expected unknown profile error listing profiles, got %v", err)
+	}
+	if err := file.UseProfile("staging"); err == nil {
+		t.Fatalf("expected error for unknown profile")
+	}
+}
+
+func TestLoadRejectsUnknownSetting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("profiles:\n  dev:\n    rancher-uri: https://x\n"), 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "rancher-uri") {
+		t.Fatalf("expected unknown setting error, got %v", err)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := Update(path, func(file *File) error {
+				return file.Set(fmt.Sprintf("profile-%d", i), "rancher-url", "https://rancher.example.com")
+			})
+			if err != nil {
+				t.Errorf("update: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(file.Profiles) != 8 {
+		t.Fatalf("expected every concurrent update to be kept, got %d profiles", len(file.Profiles))
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Fatalf("expected no leftover temp files, found %s", entry.Name())
+		}
+	}
+}
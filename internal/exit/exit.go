@@ -1,10 +1,18 @@
 package exit
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 type Error struct {
 	Code int
+	// Kind optionally categorises the failure for machine-readable output.
+	Kind string
 	Err  error
+	// Partial is set when results were still rendered; Failures lists what was skipped.
+	Partial  bool
+	Failures map[string]string
 }
 
 func (e *Error) Error() string {
@@ -21,3 +29,25 @@ func (e *Error) Unwrap() error {
 func New(code int, err error) error {
 	return &Error{Code: code, Err: err}
 }
+
+// NewAmbiguous reports results that were rendered in full but hold ambiguous matches.
+func NewAmbiguous(kind string, err error) error {
+	return &Error{Code: 3, Kind: kind, Err: err, Partial: true}
+}
+
+// NewPartial reports results that were rendered without the clusters or
+// contexts listed in failures. It exits with 4 so automation can tell missing
+// data from ambiguous matches.
+func NewPartial(kind string, err error, failures map[string]string) error {
+	return &Error{Code: 4, Kind: kind, Err: err, Partial: true, Failures: failures}
+}
+
+// AllFailed reports that every cluster or context failed, as an API error
+// unless err already carries an exit code.
+func AllFailed(err error) error {
+	var exitErr *Error
+	if errors.As(err, &exitErr) {
+		return err
+	}
+	return New(2, err)
+}
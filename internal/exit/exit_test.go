@@ -0,0 +1,34 @@
+package exit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPartialAndAmbiguousCodesDiffer(t *testing.T) {
+	var partial, ambiguous *Error
+	if !errors.As(NewPartial("partial_results", errors.New("partial"), map[string]string{"edge-02": "unreachable"}), &partial) {
+		t.Fatal("expected an exit error")
+	}
+	if !errors.As(NewAmbiguous("ambiguous_matches", errors.New("ambiguous")), &ambiguous) {
+		t.Fatal("expected an exit error")
+	}
+	if partial.Code != 4 || ambiguous.Code != 3 {
+		t.Fatalf("expected codes 4 and 3, got %d and %d", partial.Code, ambiguous.Code)
+	}
+	if !partial.Partial || partial.Failures["edge-02"] == "" {
+		t.Fatalf("expected failures on the partial error, got %+v", partial)
+	}
+}
+
+func TestAllFailedIsAnAPIError(t *testing.T) {
+	cause := errors.New("cluster unreachable")
+	var exitErr *Error
+	if !errors.As(AllFailed(cause), &exitErr) || exitErr.Code != 2 || !errors.Is(exitErr, cause) {
+		t.Fatalf("expected an API error wrapping the cause, got %v", exitErr)
+	}
+	classified := New(2, cause)
+	if got := AllFailed(classified); got != classified {
+		t.Fatalf("expected an already classified error to be kept, got %v", got)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package fileutil
+
+import "os"
+
+// Advisory locking is only implemented on unix; elsewhere the atomic rename
+// still keeps readers from seeing partial writes.
+func lockFile(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlockFile(file *os.File) error {
+	return nil
+}
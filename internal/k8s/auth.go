@@ -9,7 +9,8 @@ import (
 )
 
 // ExtractServerAndToken returns the cluster server URL and bearer token for the selected context.
-func ExtractServerAndToken(clientConfig clientcmd.ClientConfig, contextName string) (string, string, error) {
+// Exec credential plugins run as exec allows.
+func ExtractServerAndToken(clientConfig clientcmd.ClientConfig, contextName string, exec ExecPolicy) (string, string, error) {
 	if clientConfig == nil {
 		return "", "", fmt.Errorf("kubeconfig is required")
 	}
@@ -40,6 +41,12 @@ func ExtractServerAndToken(clientConfig clientcmd.ClientConfig, contextName stri
 		if token == "" && auth.TokenFile != "" {
 			token, tokenErr = readTokenFile(auth.TokenFile)
 		}
+		if token == "" && tokenErr == nil && auth.Exec != nil {
+			token, err = execCredentialToken(auth.Exec, server, exec)
+			if err != nil {
+				return "", "", err
+			}
+		}
 	}
 
 	if server == "" || token == "" {
@@ -78,3 +85,36 @@ func readTokenFile(path string) (string, error) {
 	}
 	return strings.TrimSpace(string(content)), nil
 }
+
+// ExtractCertificateAuthority returns the PEM CA bundle of the cluster behind the selected context, if any.
+func ExtractCertificateAuthority(clientConfig clientcmd.ClientConfig, contextName string) ([]byte, error) {
+	if clientConfig == nil {
+		return nil, fmt.Errorf("kubeconfig is required")
+	}
+	raw, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	if contextName == "" {
+		contextName = raw.CurrentContext
+	}
+	ctx, ok := raw.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig context not found: %s", contextName)
+	}
+	cluster, ok := raw.Clusters[ctx.Cluster]
+	if !ok {
+		return nil, nil
+	}
+	if len(cluster.CertificateAuthorityData) > 0 {
+		return cluster.CertificateAuthorityData, nil
+	}
+	if cluster.CertificateAuthority != "" {
+		data, err := os.ReadFile(expandPath(cluster.CertificateAuthority))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubeconfig certificate authority: %w", err)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
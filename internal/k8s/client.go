@@ -1,48 +1,139 @@
 package k8s
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	authorizationv1 "k8s.io/api/authorization/v1"
 	v1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 type Client struct {
 	clientset *kubernetes.Clientset
+	metadata  metadata.Interface
+	pageSize  int64
+	timeout   time.Duration
 }
 
-func NewClient(clientConfig clientcmd.ClientConfig) (*Client, error) {
+const (
+	DefaultQPS      = 50
+	DefaultBurst    = 100
+	DefaultTimeout  = 30 * time.Second
+	DefaultPageSize = 500
+)
+
+// ClientOptions tunes the Kubernetes client; zero values use the defaults.
+type ClientOptions struct {
+	QPS   float32
+	Burst int
+	// Timeout bounds each request, so a paginated listing may take longer.
+	Timeout  time.Duration
+	PageSize int64
+	// NonInteractiveExec never hands the terminal to exec credential plugins.
+	NonInteractiveExec bool
+}
+
+func NewClient(clientConfig clientcmd.ClientConfig, opts ClientOptions) (*Client, error) {
 	config, err := clientConfig.ClientConfig()
 	if err != nil {
 		return nil, &ConfigError{Kind: ErrKubeconfigInvalid, Err: err}
 	}
-	config.Timeout = 15 * time.Second
-	clientset, err := kubernetes.NewForConfig(config)
+	config.QPS = cmp.Or(opts.QPS, DefaultQPS)
+	config.Burst = cmp.Or(opts.Burst, DefaultBurst)
+	config.Timeout = cmp.Or(opts.Timeout, DefaultTimeout)
+	if opts.NonInteractiveExec && config.ExecProvider != nil {
+		config.ExecProvider.InteractiveMode = clientcmdapi.NeverExecInteractiveMode
+	}
+	httpClient, err := rest.HTTPClientFor(config)
 	if err != nil {
 		return nil, &APIError{Kind: ErrUnknown, Err: err}
 	}
-	return &Client{clientset: clientset}, nil
+	clientset, err := kubernetes.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, &APIError{Kind: ErrUnknown, Err: err}
+	}
+	metadataClient, err := metadata.NewForConfigAndClient(config, httpClient)
+	if err != nil {
+		return nil, &APIError{Kind: ErrUnknown, Err: err}
+	}
+	return &Client{clientset: clientset, metadata: metadataClient, pageSize: cmp.Or(opts.PageSize, DefaultPageSize), timeout: config.Timeout}, nil
 }
 
 func (c *Client) ListNodes(ctx context.Context, selector labels.Selector) ([]types.K8sNode, error) {
-	if selector == nil {
-		selector = labels.Everything()
+	var out []types.K8sNode
+	err := c.listPages(ctx, selector, func() { out = nil }, func(opts metav1.ListOptions) (string, error) {
+		nodes, err := c.clientset.CoreV1().Nodes().List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, normalizeNodes(nodes.Items)...)
+		return nodes.Continue, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
-	defer cancel()
+	return out, nil
+}
 
-	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+// ListNodeMetadata lists nodes as PartialObjectMetadata, which is much
+// lighter than full Node objects when only names and labels are needed.
+// Annotations are dropped; status fields stay empty.
+func (c *Client) ListNodeMetadata(ctx context.Context, selector labels.Selector) ([]types.K8sNode, error) {
+	var out []types.K8sNode
+	err := c.listPages(ctx, selector, func() { out = nil }, func(opts metav1.ListOptions) (string, error) {
+		list, err := c.metadata.Resource(v1.SchemeGroupVersion.WithResource("nodes")).List(ctx, opts)
+		if err != nil {
+			return "", err
+		}
+		for _, item := range list.Items {
+			out = append(out, normalizeNodeMetadata(item))
+		}
+		return list.Continue, nil
+	})
 	if err != nil {
-		return nil, classifyK8sError(err)
+		return nil, err
+	}
+	return out, nil
+}
+
+// listPages walks a list with limit/continue. If the continue token expires
+// mid-walk, reset discards the pages seen so far and the listing restarts once
+// without pagination, as kubectl does.
+func (c *Client) listPages(ctx context.Context, selector labels.Selector, reset func(), page func(metav1.ListOptions) (string, error)) error {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	opts := metav1.ListOptions{LabelSelector: selector.String(), Limit: c.pageSize}
+	for {
+		next, err := page(opts)
+		if err != nil && k8serrors.IsResourceExpired(err) && opts.Continue != "" && opts.Limit > 0 {
+			reset()
+			opts.Continue, opts.Limit = "", 0
+			next, err = page(opts)
+		}
+		if err != nil {
+			return classifyK8sError(err)
+		}
+		if next == "" || opts.Limit == 0 {
+			return nil
+		}
+		opts.Continue = next
 	}
-	return normalizeNodes(nodes.Items), nil
 }
 
 func normalizeNodes(items []v1.Node) []types.K8sNode {
@@ -65,28 +156,140 @@ func normalizeNode(node v1.Node) types.K8sNode {
 		}
 	}
 
-	labels := map[string]string{}
-	for key, value := range node.Labels {
-		labels[key] = value
+	// The list response is not retained, so its maps are reused as-is.
+	labels := node.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	annotations := node.Annotations
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	conditions := make([]types.NodeCondition, 0, len(node.Status.Conditions))
+	ready := false
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			ready = cond.Status == v1.ConditionTrue
+		}
+		conditions = append(conditions, types.NodeCondition{
+			Type:               string(cond.Type),
+			Status:             string(cond.Status),
+			Reason:             cond.Reason,
+			Message:            cond.Message,
+			LastTransitionTime: cond.LastTransitionTime.UTC(),
+		})
+	}
+	taints := make([]types.NodeTaint, 0, len(node.Spec.Taints))
+	for _, taint := range node.Spec.Taints {
+		taints = append(taints, types.NodeTaint{Key: taint.Key, Value: taint.Value, Effect: string(taint.Effect)})
 	}
-	annotations := map[string]string{}
-	for key, value := range node.Annotations {
-		annotations[key] = value
+
+	info := node.Status.NodeInfo
+	return types.K8sNode{
+		Name:                    node.Name,
+		UID:                     string(node.UID),
+		Labels:                  labels,
+		ProviderID:              node.Spec.ProviderID,
+		MachineID:               info.MachineID,
+		MachineName:             nodeMachineName(labels, annotations),
+		InternalIPs:             internalIPs,
+		ExternalIPs:             externalIPs,
+		Annotations:             annotations,
+		Ready:                   ready,
+		Unschedulable:           node.Spec.Unschedulable,
+		Roles:                   nodeRoles(labels),
+		Conditions:              conditions,
+		Taints:                  taints,
+		KubeletVersion:          info.KubeletVersion,
+		OSImage:                 info.OSImage,
+		KernelVersion:           info.KernelVersion,
+		ContainerRuntimeVersion: info.ContainerRuntimeVersion,
+		CreatedAt:               node.CreationTimestamp.UTC(),
+		Capacity:                resourceMap(node.Status.Capacity),
+		Allocatable:             resourceMap(node.Status.Allocatable),
 	}
+}
 
+func normalizeNodeMetadata(item metav1.PartialObjectMetadata) types.K8sNode {
+	labels := item.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
 	return types.K8sNode{
-		Name:        node.Name,
-		UID:         string(node.UID),
-		Labels:      labels,
-		ProviderID:  node.Spec.ProviderID,
-		MachineID:   node.Status.NodeInfo.MachineID,
-		MachineName: nodeMachineName(labels, annotations),
-		InternalIPs: internalIPs,
-		ExternalIPs: externalIPs,
-		Annotations: annotations,
+		Name:      item.Name,
+		UID:       string(item.UID),
+		Labels:    labels,
+		Roles:     nodeRoles(labels),
+		CreatedAt: item.CreationTimestamp.UTC(),
 	}
 }
 
+const (
+	nodeRoleLabelPrefix = "node-role.kubernetes.io/"
+	nodeRoleLabel       = "kubernetes.io/role"
+)
+
+// nodeRoles derives roles the way kubectl does: from node-role.kubernetes.io/<role>
+// label keys and the legacy kubernetes.io/role label value.
+func nodeRoles(labels map[string]string) []string {
+	var roles []string
+	for key, value := range labels {
+		switch {
+		case strings.HasPrefix(key, nodeRoleLabelPrefix):
+			roles = append(roles, strings.TrimPrefix(key, nodeRoleLabelPrefix))
+		case key == nodeRoleLabel && value != "":
+			roles = append(roles, value)
+		}
+	}
+	sort.Strings(roles)
+	return uniqueStrings(roles)
+}
+
+func resourceMap(resources v1.ResourceList) map[string]string {
+	if len(resources) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(resources))
+	for name, quantity := range resources {
+		out[string(name)] = quantity.String()
+	}
+	return out
+}
+
+// NodeStatus renders the node state like kubectl: Ready, NotReady or Unknown,
+// with SchedulingDisabled appended for cordoned nodes.
+func NodeStatus(node types.K8sNode) string {
+	status := "Unknown"
+	for _, cond := range node.Conditions {
+		if cond.Type != string(v1.NodeReady) {
+			continue
+		}
+		switch v1.ConditionStatus(cond.Status) {
+		case v1.ConditionTrue:
+			status = "Ready"
+		case v1.ConditionFalse:
+			status = "NotReady"
+		}
+	}
+	if node.Unschedulable {
+		status += ",SchedulingDisabled"
+	}
+	return status
+}
+
+// NodeHasRole reports whether the node carries any of the given roles.
+func NodeHasRole(node types.K8sNode, roles []string) bool {
+	for _, role := range roles {
+		for _, have := range node.Roles {
+			if strings.EqualFold(have, role) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func NodePrimaryInternalIP(node types.K8sNode) string {
 	if len(node.InternalIPs) == 0 {
 		return ""
@@ -201,9 +404,29 @@ func (c *Client) ServerVersion(ctx context.Context) (string, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	version, err := c.clientset.Discovery().ServerVersion()
+	// Discovery().ServerVersion takes no context, so issue the request directly.
+	raw, err := c.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
 	if err != nil {
 		return "", classifyK8sError(err)
 	}
-	return fmt.Sprintf("%s.%s", version.Major, version.Minor), nil
+	var info version.Info
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return "", &APIError{Kind: ErrUnknown, Err: fmt.Errorf("decode server version: %w", err)}
+	}
+	return fmt.Sprintf("%s.%s", info.Major, info.Minor), nil
+}
+
+// CanI runs a SelfSubjectAccessReview for a cluster-scoped core resource and
+// returns whether it is allowed, with the authorizer's reason if any.
+func (c *Client) CanI(ctx context.Context, verb, resource string) (bool, string, error) {
+	review := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: verb, Resource: resource},
+		},
+	}
+	result, err := c.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", classifyK8sError(err)
+	}
+	return result.Status.Allowed, result.Status.Reason, nil
 }
@@ -0,0 +1,318 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func TestNormalizeNodeStatus(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "node-1",
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				"node-role.kubernetes.io/control-plane": "true",
+				"node-role.kubernetes.io/etcd":          "true",
+				"kubernetes.io/role":                    "worker",
+			},
+		},
+		Spec: v1.NodeSpec{
+			Unschedulable: true,
+			Taints:        []v1.Taint{{Key: "node.kubernetes.io/unschedulable", Effect: v1.TaintEffectNoSchedule}},
+		},
+		Status: v1.NodeStatus{
+			Conditions: []v1.NodeCondition{
+				{Type: v1.NodeMemoryPressure, Status: v1.ConditionFalse},
+				{Type: v1.NodeReady, Status: v1.ConditionTrue, Reason: "KubeletReady"},
+			},
+			NodeInfo: v1.NodeSystemInfo{
+				KubeletVersion:          "v1.30.4+rke2r1",
+				OSImage:                 "SLE Micro 6.0",
+				KernelVersion:           "6.4.0",
+				ContainerRuntimeVersion: "containerd://1.7.17",
+			},
+			Capacity:    v1.ResourceList{v1.ResourceCPU: resource.MustParse("16"), v1.ResourceMemory: resource.MustParse("64Gi")},
+			Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("15500m")},
+		},
+	}
+
+	got := normalizeNode(node)
+	if !got.Ready || !got.Unschedulable {
+		t.Fatalf("expected ready and unschedulable, got ready=%v unschedulable=%v", got.Ready, got.Unschedulable)
+	}
+	if want := []string{"control-plane", "etcd", "worker"}; !reflect.DeepEqual(got.Roles, want) {
+		t.Fatalf("expected roles %v, got %v", want, got.Roles)
+	}
+	if len(got.Conditions) != 2 || got.Conditions[1].Reason != "KubeletReady" {
+		t.Fatalf("unexpected conditions: %+v", got.Conditions)
+	}
+	if len(got.Taints) != 1 || got.Taints[0].Effect != "NoSchedule" {
+		t.Fatalf("unexpected taints: %+v", got.Taints)
+	}
+	if got.KubeletVersion != "v1.30.4+rke2r1" || got.ContainerRuntimeVersion != "containerd://1.7.17" {
+		t.Fatalf("unexpected node info: %+v", got)
+	}
+	if !got.CreatedAt.Equal(created) {
+		t.Fatalf("expected creation time %s, got %s", created, got.CreatedAt)
+	}
+	if got.Capacity["memory"] != "64Gi" || got.Allocatable["cpu"] != "15500m" {
+		t.Fatalf("unexpected resources: capacity=%v allocatable=%v", got.Capacity, got.Allocatable)
+	}
+	if status := NodeStatus(got); status != "Ready,SchedulingDisabled" {
+		t.Fatalf("expected Ready,SchedulingDisabled, got %s", status)
+	}
+	if !NodeHasRole(got, []string{"Worker"}) || NodeHasRole(got, []string{"storage"}) {
+		t.Fatalf("unexpected role match for %v", got.Roles)
+	}
+}
+
+func TestNodeStatusWithoutReadyCondition(t *testing.T) {
+	got := normalizeNode(v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-2"}})
+	if got.Ready {
+		t.Fatalf("expected node without conditions to be not ready")
+	}
+	if status := NodeStatus(got); status != "Unknown" {
+		t.Fatalf("expected Unknown, got %s", status)
+	}
+}
+
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ClientOptions) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	config := clientcmdapi.NewConfig()
+	config.Clusters["test"] = &clientcmdapi.Cluster{Server: server.URL}
+	config.AuthInfos["test"] = &clientcmdapi.AuthInfo{Token: "dummy"}
+	config.Contexts["test"] = &clientcmdapi.Context{Cluster: "test", AuthInfo: "test"}
+	config.CurrentContext = "test"
+	client, err := NewClient(clientcmd.NewDefaultClientConfig(*config, nil), opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return client
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, status int, value any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		t.Errorf("encode response: %v", err)
+	}
+}
+
+func nodeList(cont string, names ...string) v1.NodeList {
+	list := v1.NodeList{TypeMeta: metav1.TypeMeta{Kind: "NodeList", APIVersion: "v1"}, ListMeta: metav1.ListMeta{Continue: cont}}
+	for _, name := range names {
+		list.Items = append(list.Items, v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return list
+}
+
+func TestListNodesPaginates(t *testing.T) {
+	var requests atomic.Int32
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		query := r.URL.Query()
+		if query.Get("limit") != "2" {
+			t.Errorf("expected limit=2, got %q", query.Get("limit"))
+		}
+		if query.Get("labelSelector") != "env=prod" {
+			t.Errorf("expected labelSelector env=prod, got %q", query.Get("labelSelector"))
+		}
+		switch query.Get("continue") {
+		case "":
+			writeJSON(t, w, http.StatusOK, nodeList("page-2", "node-1", "node-2"))
+		case "page-2":
+			writeJSON(t, w, http.StatusOK, nodeList("", "node-3"))
+		default:
+			t.Errorf("unexpected continue token %q", query.Get("continue"))
+		}
+	}, ClientOptions{PageSize: 2})
+
+	nodes, err := client.ListNodes(context.Background(), labels.SelectorFromSet(labels.Set{"env": "prod"}))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 3 || nodes[2].Name != "node-3" {
+		t.Fatalf("expected 3 nodes across pages, got %+v", nodes)
+	}
+	if requests.Load() != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests.Load())
+	}
+}
+
+func TestListNodesRestartsOnExpiredContinue(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("continue") == "stale":
+			writeJSON(t, w, http.StatusGone, metav1.Status{
+				TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+				Status:   metav1.StatusFailure,
+				Reason:   metav1.StatusReasonExpired,
+				Code:     http.StatusGone,
+			})
+		case query.Get("limit") == "":
+			writeJSON(t, w, http.StatusOK, nodeList("", "node-1", "node-2", "node-3"))
+		default:
+			writeJSON(t, w, http.StatusOK, nodeList("stale", "node-1"))
+		}
+	}, ClientOptions{PageSize: 1})
+
+	nodes, err := client.ListNodes(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 3 {
+		t.Fatalf("expected the restarted listing to replace partial pages, got %d nodes", len(nodes))
+	}
+}
+
+func TestListNodeMetadata(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/nodes" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		writeJSON(t, w, http.StatusOK, metav1.PartialObjectMetadataList{
+			TypeMeta: metav1.TypeMeta{Kind: "PartialObjectMetadataList", APIVersion: "meta.k8s.io/v1"},
+			Items: []metav1.PartialObjectMetadata{{
+				TypeMeta: metav1.TypeMeta{Kind: "PartialObjectMetadata", APIVersion: "meta.k8s.io/v1"},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "node-1",
+					Labels:      map[string]string{"node-role.kubernetes.io/worker": "true"},
+					Annotations: map[string]string{"heavy": "annotation"},
+				},
+			}},
+		})
+	}, ClientOptions{})
+
+	nodes, err := client.ListNodeMetadata(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "node-1" {
+		t.Fatalf("unexpected nodes: %+v", nodes)
+	}
+	if !reflect.DeepEqual(nodes[0].Roles, []string{"worker"}) || nodes[0].Annotations != nil {
+		t.Fatalf("expected roles from labels and no annotations, got %+v", nodes[0])
+	}
+}
+
+func TestListNodesImpersonates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Impersonate-User"); got != "alice" {
+			t.Errorf("expected Impersonate-User alice, got %q", got)
+		}
+		// --as keeps the kubeconfig's as-groups, as kubectl does.
+		if got := r.Header.Values("Impersonate-Group"); !reflect.DeepEqual(got, []string{"ops"}) {
+			t.Errorf("unexpected Impersonate-Group %v", got)
+		}
+		writeJSON(t, w, http.StatusOK, nodeList("", "node-1"))
+	}))
+	defer server.Close()
+	content := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: ` + server.URL + `
+users:
+- name: test
+  user:
+    token: dummy
+    as: bob
+    as-groups: [ops]
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+current-context: test
+`)
+	clientConfig, _, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{UserName: "alice"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client, err := NewClient(clientConfig, ClientOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.ListNodes(context.Background(), nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestWatchNodesFailsWhenListIsForbidden(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusForbidden, metav1.Status{
+			TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+			Status:   metav1.StatusFailure,
+			Reason:   metav1.StatusReasonForbidden,
+			Code:     http.StatusForbidden,
+		})
+	}, ClientOptions{Timeout: time.Minute})
+
+	start := time.Now()
+	_, err := client.WatchNodes(context.Background(), nil, nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrForbidden {
+		t.Fatalf("expected a forbidden APIError, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected the sync to fail on the first error, took %s", elapsed)
+	}
+}
+
+func TestServerVersionHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}, ClientOptions{Timeout: time.Minute})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.ServerVersion(ctx); err == nil {
+		t.Fatal("expected an error when the context expires")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("expected the request to stop with the context, took %s", elapsed)
+	}
+}
+
+func TestServerVersion(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(t, w, http.StatusOK, map[string]string{"major": "1", "minor": "31"})
+	}, ClientOptions{})
+	got, err := client.ServerVersion(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "1.31" {
+		t.Fatalf("expected 1.31, got %s", got)
+	}
+}
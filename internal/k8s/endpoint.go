@@ -0,0 +1,50 @@
+package k8s
+
+import (
+	"fmt"
+	"slices"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// EndpointMode selects how a Rancher-generated kubeconfig reaches the
+// downstream cluster: through the Rancher proxy or an authorized cluster
+// endpoint (ACE).
+type EndpointMode string
+
+const (
+	EndpointAuto   EndpointMode = "auto"
+	EndpointProxy  EndpointMode = "proxy"
+	EndpointDirect EndpointMode = "direct"
+)
+
+func ParseEndpointMode(raw string) (EndpointMode, error) {
+	switch mode := EndpointMode(raw); mode {
+	case EndpointAuto, EndpointProxy, EndpointDirect:
+		return mode, nil
+	case "":
+		return EndpointProxy, nil
+	default:
+		return "", fmt.Errorf("invalid endpoint mode: %s", raw)
+	}
+}
+
+// EndpointContexts returns the contexts to try, in order: the current
+// (proxy) context for proxy, the ACE contexts for direct, and the ACE
+// contexts followed by the proxy context for auto.
+func EndpointContexts(config *clientcmdapi.Config, mode EndpointMode) ([]string, error) {
+	if mode == EndpointProxy {
+		return []string{config.CurrentContext}, nil
+	}
+	direct := DirectContexts(config)
+	if mode == EndpointDirect {
+		if len(direct) == 0 {
+			return nil, &ConfigError{Kind: ErrContextNotFound, Err: fmt.Errorf("no authorized cluster endpoint context; enable ACE on the cluster or use --endpoint proxy")}
+		}
+		return direct, nil
+	}
+	if config.CurrentContext != "" && !slices.Contains(direct, config.CurrentContext) {
+		direct = append(direct, config.CurrentContext)
+	}
+	return direct, nil
+}
@@ -0,0 +1,180 @@
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const execCredentialTimeout = time.Minute
+
+// execCredentialSkew drops cached credentials slightly before they expire.
+const execCredentialSkew = time.Minute
+
+// CredentialCache keeps exec plugin credentials between runs until they
+// expire; rancher.Cache satisfies it.
+type CredentialCache interface {
+	Get(name string) ([]byte, bool, error)
+	Put(name string, data []byte) error
+}
+
+// ExecPolicy controls how ExtractServerAndToken runs exec credential plugins.
+type ExecPolicy struct {
+	// Cache keeps credentials until they expire; nil disables caching.
+	Cache CredentialCache
+	// CachedOnly never starts the plugin and fails when nothing is cached.
+	CachedOnly bool
+}
+
+type execCredential struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Interactive bool `json:"interactive"`
+	} `json:"spec"`
+	Status *struct {
+		Token               string     `json:"token"`
+		ExpirationTimestamp *time.Time `json:"expirationTimestamp,omitempty"`
+	} `json:"status,omitempty"`
+}
+
+type cachedExecCredential struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// execCredentialToken runs a kubeconfig exec credential plugin and returns the bearer token it prints.
+// Credentials carrying an expirationTimestamp are kept in the policy's cache,
+// when given, until shortly before they expire.
+func execCredentialToken(config *clientcmdapi.ExecConfig, server string, policy ExecPolicy) (string, error) {
+	if config == nil || config.Command == "" {
+		return "", fmt.Errorf("exec plugin command is empty")
+	}
+	apiVersion := config.APIVersion
+	if apiVersion == "" {
+		apiVersion = "client.authentication.k8s.io/v1"
+	}
+	cache := policy.Cache
+	cacheName := execCredentialCacheName(config, server)
+	if cache != nil {
+		if token, ok := loadExecCredential(cache, cacheName, time.Now()); ok {
+			return token, nil
+		}
+	}
+	if policy.CachedOnly {
+		return "", fmt.Errorf("exec plugin %s has no cached credential", config.Command)
+	}
+
+	interactive, err := execInteractive(config.InteractiveMode, term.IsTerminal(int(os.Stdin.Fd())))
+	if err != nil {
+		return "", fmt.Errorf("exec plugin %s: %w", config.Command, err)
+	}
+	var request execCredential
+	request.APIVersion = apiVersion
+	request.Kind = "ExecCredential"
+	request.Spec.Interactive = interactive
+	info, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), execCredentialTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, expandPath(config.Command), config.Args...)
+	cmd.Env = os.Environ()
+	for _, env := range config.Env {
+		cmd.Env = append(cmd.Env, env.Name+"="+env.Value)
+	}
+	cmd.Env = append(cmd.Env, "KUBERNETES_EXEC_INFO="+string(info))
+	if interactive {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		if config.InstallHint != "" {
+			return "", fmt.Errorf("exec plugin %s failed: %w (%s)", config.Command, err, strings.TrimSpace(config.InstallHint))
+		}
+		return "", fmt.Errorf("exec plugin %s failed: %w", config.Command, err)
+	}
+
+	var response execCredential
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		return "", fmt.Errorf("exec plugin %s returned invalid ExecCredential: %w", config.Command, err)
+	}
+	if response.Kind != "ExecCredential" {
+		return "", fmt.Errorf("exec plugin %s returned kind %q, expected ExecCredential", config.Command, response.Kind)
+	}
+	if response.Status == nil || strings.TrimSpace(response.Status.Token) == "" {
+		return "", fmt.Errorf("exec plugin %s returned no bearer token", config.Command)
+	}
+	token := strings.TrimSpace(response.Status.Token)
+	if cache != nil && response.Status.ExpirationTimestamp != nil {
+		// Caching is best effort; the token is still usable without it.
+		_ = saveExecCredential(cache, cacheName, cachedExecCredential{Token: token, ExpiresAt: *response.Status.ExpirationTimestamp})
+	}
+	return token, nil
+}
+
+// execInteractive applies client-go's rule: IfAvailable (the default) is
+// interactive only when stdin is a terminal, and Always requires one.
+func execInteractive(mode clientcmdapi.ExecInteractiveMode, stdinTerminal bool) (bool, error) {
+	switch mode {
+	case clientcmdapi.NeverExecInteractiveMode:
+		return false, nil
+	case clientcmdapi.AlwaysExecInteractiveMode:
+		if !stdinTerminal {
+			return false, fmt.Errorf("interactiveMode is Always but stdin is not a terminal")
+		}
+		return true, nil
+	default:
+		return stdinTerminal, nil
+	}
+}
+
+func loadExecCredential(cache CredentialCache, name string, now time.Time) (string, bool) {
+	data, ok, err := cache.Get(name)
+	if err != nil || !ok {
+		return "", false
+	}
+	var cached cachedExecCredential
+	if err := json.Unmarshal(data, &cached); err != nil || cached.Token == "" {
+		return "", false
+	}
+	if !now.Add(execCredentialSkew).Before(cached.ExpiresAt) {
+		return "", false
+	}
+	return cached.Token, true
+}
+
+func saveExecCredential(cache CredentialCache, name string, cached cachedExecCredential) error {
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return err
+	}
+	return cache.Put(name, data)
+}
+
+// execCredentialCacheName identifies a plugin invocation by everything that
+// may change the credential it returns.
+func execCredentialCacheName(config *clientcmdapi.ExecConfig, server string) string {
+	parts := append([]string{server, config.APIVersion, config.Command}, config.Args...)
+	for _, env := range config.Env {
+		parts = append(parts, env.Name+"="+env.Value)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return "exec-credential-" + hex.EncodeToString(sum[:16]) + ".json"
+}
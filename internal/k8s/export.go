@@ -0,0 +1,101 @@
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// rancherProxyPath marks servers reached through the Rancher proxy rather
+// than an authorized cluster endpoint.
+const rancherProxyPath = "/k8s/clusters/"
+
+var ErrEntryExists = errors.New("kubeconfig entry already exists")
+
+// DirectContexts returns, sorted, the contexts of a Rancher-generated
+// kubeconfig that reach the cluster through an authorized cluster endpoint.
+func DirectContexts(config *clientcmdapi.Config) []string {
+	var names []string
+	for name, context := range config.Contexts {
+		cluster, ok := config.Clusters[context.Cluster]
+		if ok && cluster.Server != "" && !strings.Contains(cluster.Server, rancherProxyPath) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ExtractContext returns a kubeconfig holding only contextName, with its
+// context, cluster and user all renamed to name.
+func ExtractContext(config *clientcmdapi.Config, contextName, name string) (*clientcmdapi.Config, error) {
+	context, ok := config.Contexts[contextName]
+	if !ok {
+		return nil, &ConfigError{Kind: ErrContextNotFound, Err: fmt.Errorf("context %q not found", contextName)}
+	}
+	cluster, ok := config.Clusters[context.Cluster]
+	if !ok {
+		return nil, &ConfigError{Kind: ErrKubeconfigInvalid, Err: fmt.Errorf("context %q references missing cluster %q", contextName, context.Cluster)}
+	}
+	out := clientcmdapi.NewConfig()
+	out.Clusters[name] = cluster.DeepCopy()
+	if context.AuthInfo != "" {
+		user, ok := config.AuthInfos[context.AuthInfo]
+		if !ok {
+			return nil, &ConfigError{Kind: ErrKubeconfigInvalid, Err: fmt.Errorf("context %q references missing user %q", contextName, context.AuthInfo)}
+		}
+		out.AuthInfos[name] = user.DeepCopy()
+	}
+	renamed := context.DeepCopy()
+	renamed.Cluster = name
+	if context.AuthInfo != "" {
+		renamed.AuthInfo = name
+	}
+	out.Contexts[name] = renamed
+	out.CurrentContext = name
+	return out, nil
+}
+
+// MergeKubeconfig adds the clusters, users and contexts of src to dst. Names
+// that already exist in dst are an ErrEntryExists error unless replace is
+// set. dst keeps its current context unless it has none.
+func MergeKubeconfig(dst, src *clientcmdapi.Config, replace bool) error {
+	if !replace {
+		var existing []string
+		for name := range src.Clusters {
+			if _, ok := dst.Clusters[name]; ok {
+				existing = append(existing, "cluster "+name)
+			}
+		}
+		for name := range src.AuthInfos {
+			if _, ok := dst.AuthInfos[name]; ok {
+				existing = append(existing, "user "+name)
+			}
+		}
+		for name := range src.Contexts {
+			if _, ok := dst.Contexts[name]; ok {
+				existing = append(existing, "context "+name)
+			}
+		}
+		if len(existing) > 0 {
+			slices.Sort(existing)
+			return fmt.Errorf("%w: %s", ErrEntryExists, strings.Join(existing, ", "))
+		}
+	}
+	for name, cluster := range src.Clusters {
+		dst.Clusters[name] = cluster.DeepCopy()
+	}
+	for name, user := range src.AuthInfos {
+		dst.AuthInfos[name] = user.DeepCopy()
+	}
+	for name, context := range src.Contexts {
+		dst.Contexts[name] = context.DeepCopy()
+	}
+	if dst.CurrentContext == "" {
+		dst.CurrentContext = src.CurrentContext
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func rancherKubeconfig() *clientcmdapi.Config {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["shared"] = &clientcmdapi.Cluster{Server: "https://rancher.example.com/k8s/clusters/c-m-abc"}
+	config.Clusters["shared-cp1"] = &clientcmdapi.Cluster{Server: "https://10.0.0.10:6443", CertificateAuthorityData: []byte("ca")}
+	config.AuthInfos["shared"] = &clientcmdapi.AuthInfo{Token: "kubeconfig-u-1:secret"}
+	config.Contexts["shared"] = &clientcmdapi.Context{Cluster: "shared", AuthInfo: "shared"}
+	config.Contexts["shared-cp1"] = &clientcmdapi.Context{Cluster: "shared-cp1", AuthInfo: "shared"}
+	config.CurrentContext = "shared"
+	return config
+}
+
+func TestDirectContexts(t *testing.T) {
+	if got := DirectContexts(rancherKubeconfig()); !reflect.DeepEqual(got, []string{"shared-cp1"}) {
+		t.Fatalf("unexpected direct contexts %v", got)
+	}
+}
+
+func TestExtractContextRenames(t *testing.T) {
+	out, err := ExtractContext(rancherKubeconfig(), "shared-cp1", "mtl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.CurrentContext != "mtl" || len(out.Contexts) != 1 || len(out.Clusters) != 1 || len(out.AuthInfos) != 1 {
+		t.Fatalf("expected a single renamed context, got %+v", out)
+	}
+	context := out.Contexts["mtl"]
+	if context.Cluster != "mtl" || context.AuthInfo != "mtl" {
+		t.Fatalf("unexpected context %+v", context)
+	}
+	if out.Clusters["mtl"].Server != "https://10.0.0.10:6443" || out.AuthInfos["mtl"].Token != "kubeconfig-u-1:secret" {
+		t.Fatalf("unexpected cluster or user %+v %+v", out.Clusters["mtl"], out.AuthInfos["mtl"])
+	}
+	if _, err := ExtractContext(rancherKubeconfig(), "missing", "mtl"); err == nil {
+		t.Fatalf("expected error for missing context")
+	}
+}
+
+func TestMergeKubeconfigDoesNotClobber(t *testing.T) {
+	dst := clientcmdapi.NewConfig()
+	dst.Clusters["mtl"] = &clientcmdapi.Cluster{Server: "https://old.example.com"}
+	dst.Contexts["home"] = &clientcmdapi.Context{Cluster: "mtl"}
+	dst.CurrentContext = "home"
+
+	src, err := ExtractContext(rancherKubeconfig(), "shared", "mtl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := MergeKubeconfig(dst, src, false); !errors.Is(err, ErrEntryExists) {
+		t.Fatalf("expected ErrEntryExists, got %v", err)
+	}
+	if dst.Clusters["mtl"].Server != "https://old.example.com" || len(dst.Contexts) != 1 {
+		t.Fatalf("expected dst untouched after a conflict, got %+v", dst)
+	}
+
+	if err := MergeKubeconfig(dst, src, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dst.Clusters["mtl"].Server != "https://rancher.example.com/k8s/clusters/c-m-abc" {
+		t.Fatalf("expected replaced cluster, got %+v", dst.Clusters["mtl"])
+	}
+	if dst.CurrentContext != "home" || dst.Contexts["mtl"] == nil || dst.AuthInfos["mtl"] == nil {
+		t.Fatalf("unexpected merge result %+v", dst)
+	}
+}
+
+func TestEndpointContexts(t *testing.T) {
+	config := rancherKubeconfig()
+	cases := map[EndpointMode][]string{
+		EndpointProxy:  {"shared"},
+		EndpointDirect: {"shared-cp1"},
+		EndpointAuto:   {"shared-cp1", "shared"},
+	}
+	for mode, want := range cases {
+		got, err := EndpointContexts(config, mode)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: expected %v, got %v (%v)", mode, want, got, err)
+		}
+	}
+
+	delete(config.Contexts, "shared-cp1")
+	if got, err := EndpointContexts(config, EndpointAuto); err != nil || !reflect.DeepEqual(got, []string{"shared"}) {
+		t.Fatalf("expected auto to fall back to the proxy, got %v (%v)", got, err)
+	}
+	if _, err := EndpointContexts(config, EndpointDirect); err == nil {
+		t.Fatalf("expected direct to fail without an ACE context")
+	}
+}
@@ -2,10 +2,14 @@ package k8s
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
@@ -15,7 +19,105 @@ type KubeconfigInfo struct {
 	Context string
 }
 
-func ResolveKubeconfig(explicitPath, contextOverride string) (clientcmd.ClientConfig, KubeconfigInfo, error) {
+// ResolveKubeconfig loads the kubeconfig like kubectl. A non-empty as is
+// merged into the selected user like kubectl --as/--as-group/--as-uid.
+func ResolveKubeconfig(explicitPath, contextOverride string, as rest.ImpersonationConfig) (clientcmd.ClientConfig, KubeconfigInfo, error) {
+	rules, info, err := loadingRules(explicitPath)
+	if err != nil {
+		return nil, info, err
+	}
+	paths := info.Paths
+
+	overrides := configOverrides(contextOverride, as)
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
+	rawConfig, err := clientConfig.RawConfig()
+	if err != nil {
+		return nil, info, &ConfigError{Kind: ErrKubeconfigInvalid, Paths: paths, Err: err}
+	}
+
+	contextName := overrides.CurrentContext
+	if contextName == "" {
+		contextName = rawConfig.CurrentContext
+	}
+	if contextName == "" {
+		return nil, info, &ConfigError{Kind: ErrKubeconfigInvalid, Paths: paths, Err: fmt.Errorf("missing current context")}
+	}
+	info.Context = contextName
+	if _, ok := rawConfig.Contexts[contextName]; !ok {
+		return nil, info, &ConfigError{Kind: ErrContextNotFound, Paths: paths, Err: fmt.Errorf("context %q not found", contextName)}
+	}
+	return clientConfig, info, nil
+}
+
+// ListContexts returns the sorted context names of the kubeconfig that
+// ResolveKubeconfig would load, without requiring a current context.
+func ListContexts(explicitPath string) ([]string, error) {
+	rules, info, err := loadingRules(explicitPath)
+	if err != nil {
+		return nil, err
+	}
+	rawConfig, err := rules.Load()
+	if err != nil {
+		return nil, &ConfigError{Kind: ErrKubeconfigInvalid, Paths: info.Paths, Err: err}
+	}
+	return slices.Sorted(maps.Keys(rawConfig.Contexts)), nil
+}
+
+// SelectContexts picks the named contexts, in order, followed by the sorted
+// contexts matching the glob pattern. Unknown names, an invalid pattern or a
+// pattern matching nothing are errors.
+func SelectContexts(available, names []string, pattern string) ([]string, error) {
+	seen := map[string]bool{}
+	var selected []string
+	for _, name := range names {
+		if !slices.Contains(available, name) {
+			return nil, &ConfigError{Kind: ErrContextNotFound, Err: fmt.Errorf("context %q not found", name)}
+		}
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, name)
+		}
+	}
+	if pattern == "" {
+		return selected, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid context pattern %q: %w", pattern, err)
+	}
+	matched := 0
+	for _, name := range slices.Sorted(slices.Values(available)) {
+		if ok, _ := path.Match(pattern, name); !ok {
+			continue
+		}
+		matched++
+		if !seen[name] {
+			seen[name] = true
+			selected = append(selected, name)
+		}
+	}
+	if matched == 0 {
+		return nil, &ConfigError{Kind: ErrContextNotFound, Err: fmt.Errorf("no context matches %q", pattern)}
+	}
+	return selected, nil
+}
+
+// WritePath returns the kubeconfig file that changes are written to, like
+// kubectl config: the explicit path, else the first $KUBECONFIG entry, else
+// ~/.kube/config.
+func WritePath(explicitPath string) string {
+	if explicitPath != "" {
+		return expandPath(explicitPath)
+	}
+	for _, path := range filepath.SplitList(os.Getenv("KUBECONFIG")) {
+		if path = expandPath(path); path != "" {
+			return path
+		}
+	}
+	return clientcmd.RecommendedHomeFile
+}
+
+func loadingRules(explicitPath string) (*clientcmd.ClientConfigLoadingRules, KubeconfigInfo, error) {
 	info := KubeconfigInfo{}
 	rules := clientcmd.NewDefaultClientConfigLoadingRules()
 	paths := []string{}
@@ -37,37 +139,13 @@ func ResolveKubeconfig(explicitPath, contextOverride string) (clientcmd.ClientCo
 	}
 	info.Paths = paths
 
-	existing := existingPaths(paths)
-	if len(existing) == 0 {
+	if len(existingPaths(paths)) == 0 {
 		return nil, info, &ConfigError{Kind: ErrKubeconfigNotFound, Paths: paths}
 	}
-
-	overrides := &clientcmd.ConfigOverrides{}
-	if contextOverride != "" {
-		overrides.CurrentContext = contextOverride
-	}
-
-	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides)
-	rawConfig, err := clientConfig.RawConfig()
-	if err != nil {
-		return nil, info, &ConfigError{Kind: ErrKubeconfigInvalid, Paths: paths, Err: err}
-	}
-
-	contextName := overrides.CurrentContext
-	if contextName == "" {
-		contextName = rawConfig.CurrentContext
-	}
-	if contextName == "" {
-		return nil, info, &ConfigError{Kind: ErrKubeconfigInvalid, Paths: paths, Err: fmt.Errorf("missing current context")}
-	}
-	info.Context = contextName
-	if _, ok := rawConfig.Contexts[contextName]; !ok {
-		return nil, info, &ConfigError{Kind: ErrContextNotFound, Paths: paths, Err: fmt.Errorf("context %q not found", contextName)}
-	}
-	return clientConfig, info, nil
+	return rules, info, nil
 }
 
-func ResolveKubeconfigFromBytes(content []byte, source string, paths []string, contextOverride string) (clientcmd.ClientConfig, KubeconfigInfo, error) {
+func ResolveKubeconfigFromBytes(content []byte, source string, paths []string, contextOverride string, as rest.ImpersonationConfig) (clientcmd.ClientConfig, KubeconfigInfo, error) {
 	info := KubeconfigInfo{Source: source, Paths: paths}
 	if info.Source == "" {
 		info.Source = "inline"
@@ -77,10 +155,7 @@ func ResolveKubeconfigFromBytes(content []byte, source string, paths []string, c
 		return nil, info, &ConfigError{Kind: ErrKubeconfigInvalid, Paths: paths, Err: err}
 	}
 
-	overrides := &clientcmd.ConfigOverrides{}
-	if contextOverride != "" {
-		overrides.CurrentContext = contextOverride
-	}
+	overrides := configOverrides(contextOverride, as)
 
 	contextName := overrides.CurrentContext
 	if contextName == "" {
@@ -98,6 +173,18 @@ func ResolveKubeconfigFromBytes(content []byte, source string, paths []string, c
 	return clientConfig, info, nil
 }
 
+// configOverrides is what kubectl builds from --context and --as flags;
+// empty fields leave the kubeconfig's values in place.
+func configOverrides(contextOverride string, as rest.ImpersonationConfig) *clientcmd.ConfigOverrides {
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextOverride}
+	if as.UserName != "" {
+		overrides.AuthInfo.Impersonate = as.UserName
+		overrides.AuthInfo.ImpersonateGroups = as.Groups
+		overrides.AuthInfo.ImpersonateUID = as.UID
+	}
+	return overrides
+}
+
 func existingPaths(paths []string) []string {
 	var existing []string
 	for _, path := range paths {
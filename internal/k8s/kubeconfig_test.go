@@ -5,7 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"k8s.io/client-go/rest"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 )
 
 const sampleConfigTemplate = `apiVersion: v1
@@ -33,7 +39,7 @@ func TestResolveKubeconfigFlagOverridesEnv(t *testing.T) {
 
 	t.Setenv("KUBECONFIG", envPath)
 
-	_, info, err := ResolveKubeconfig(flagPath, "")
+	_, info, err := ResolveKubeconfig(flagPath, "", rest.ImpersonationConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -50,7 +56,7 @@ func TestResolveKubeconfigEnv(t *testing.T) {
 	envPath := writeConfig(t, tempDir, "env.yaml", "env-context")
 	t.Setenv("KUBECONFIG", envPath)
 
-	_, info, err := ResolveKubeconfig("", "")
+	_, info, err := ResolveKubeconfig("", "", rest.ImpersonationConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -66,7 +72,7 @@ func TestResolveKubeconfigContextOverride(t *testing.T) {
 	tempDir := t.TempDir()
 	path := writeConfig(t, tempDir, "config.yaml", "default-context")
 
-	_, info, err := ResolveKubeconfig(path, "override-context")
+	_, info, err := ResolveKubeconfig(path, "override-context", rest.ImpersonationConfig{})
 	if err == nil {
 		t.Fatalf("expected context error, got nil")
 	}
@@ -81,7 +87,7 @@ func TestResolveKubeconfigContextOverride(t *testing.T) {
 
 func TestResolveKubeconfigNotFound(t *testing.T) {
 	t.Setenv("KUBECONFIG", filepath.Join(t.TempDir(), "missing.yaml"))
-	_, _, err := ResolveKubeconfig("", "")
+	_, _, err := ResolveKubeconfig("", "", rest.ImpersonationConfig{})
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -93,7 +99,7 @@ func TestResolveKubeconfigNotFound(t *testing.T) {
 
 func TestResolveKubeconfigFromBytes(t *testing.T) {
 	content := []byte(fmt.Sprintf(sampleConfigTemplate, "byte-context", "byte-context"))
-	_, info, err := ResolveKubeconfigFromBytes(content, "rancher", []string{"cluster:c-123"}, "")
+	_, info, err := ResolveKubeconfigFromBytes(content, "rancher", []string{"cluster:c-123"}, "", rest.ImpersonationConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -107,7 +113,7 @@ func TestResolveKubeconfigFromBytes(t *testing.T) {
 
 func TestResolveKubeconfigFromBytesMissingContext(t *testing.T) {
 	content := []byte(fmt.Sprintf(sampleConfigTemplate, "byte-context", "byte-context"))
-	_, _, err := ResolveKubeconfigFromBytes(content, "rancher", nil, "missing")
+	_, _, err := ResolveKubeconfigFromBytes(content, "rancher", nil, "missing", rest.ImpersonationConfig{})
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -119,11 +125,11 @@ func TestResolveKubeconfigFromBytesMissingContext(t *testing.T) {
 
 func TestExtractServerAndToken(t *testing.T) {
 	content := []byte(fmt.Sprintf(sampleConfigTemplate, "byte-context", "byte-context"))
-	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "")
+	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	server, token, err := ExtractServerAndToken(clientConfig, info.Context)
+	server, token, err := ExtractServerAndToken(clientConfig, info.Context, ExecPolicy{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -152,16 +158,233 @@ users:
 - name: test
   user: {}
 `)
-	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "")
+	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	_, _, err = ExtractServerAndToken(clientConfig, info.Context)
+	_, _, err = ExtractServerAndToken(clientConfig, info.Context, ExecPolicy{})
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
 }
 
+func TestExtractServerAndTokenExecPlugin(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://example.com
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: ctx
+current-context: ctx
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: sh
+      args:
+      - -c
+      - 'echo "{\"apiVersion\":\"client.authentication.k8s.io/v1\",\"kind\":\"ExecCredential\",\"status\":{\"token\":\"$PLUGIN_TOKEN\"}}"'
+      env:
+      - name: PLUGIN_TOKEN
+        value: from-exec
+      interactiveMode: Never
+`)
+	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, token, err := ExtractServerAndToken(clientConfig, info.Context, ExecPolicy{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if token != "from-exec" {
+		t.Fatalf("expected token from-exec, got %s", token)
+	}
+}
+
+type memoryCredentialCache map[string][]byte
+
+func (c memoryCredentialCache) Get(name string) ([]byte, bool, error) {
+	data, ok := c[name]
+	return data, ok, nil
+}
+
+func (c memoryCredentialCache) Put(name string, data []byte) error {
+	c[name] = data
+	return nil
+}
+
+func TestExtractServerAndTokenCachesExecCredential(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "runs")
+	expiry := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	content := []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://example.com
+  name: test
+contexts:
+- context:
+    cluster: test
+    user: test
+  name: ctx
+current-context: ctx
+users:
+- name: test
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1
+      command: sh
+      args:
+      - -c
+      - 'echo run >> %s; echo "{\"apiVersion\":\"client.authentication.k8s.io/v1\",\"kind\":\"ExecCredential\",\"status\":{\"token\":\"cached\",\"expirationTimestamp\":\"%s\"}}"'
+      interactiveMode: Never
+`, counter, expiry))
+	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, err := ExtractServerAndToken(clientConfig, info.Context, ExecPolicy{Cache: memoryCredentialCache{}, CachedOnly: true}); err == nil {
+		t.Fatal("expected a cache miss to fail when only cached credentials are allowed")
+	}
+	if _, err := os.Stat(counter); !os.IsNotExist(err) {
+		t.Fatalf("expected the plugin not to run on a cached-only miss, stat err=%v", err)
+	}
+	cache := memoryCredentialCache{}
+	for range 2 {
+		_, token, err := ExtractServerAndToken(clientConfig, info.Context, ExecPolicy{Cache: cache})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != "cached" {
+			t.Fatalf("expected token cached, got %s", token)
+		}
+	}
+	runs, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Count(string(runs), "run"); got != 1 {
+		t.Fatalf("expected the plugin to run once, ran %d times", got)
+	}
+}
+
+func TestExecInteractive(t *testing.T) {
+	cases := []struct {
+		mode        clientcmdapi.ExecInteractiveMode
+		terminal    bool
+		interactive bool
+		wantErr     bool
+	}{
+		{mode: clientcmdapi.NeverExecInteractiveMode, terminal: true},
+		{mode: clientcmdapi.IfAvailableExecInteractiveMode, terminal: false},
+		{mode: clientcmdapi.IfAvailableExecInteractiveMode, terminal: true, interactive: true},
+		{mode: "", terminal: true, interactive: true},
+		{mode: clientcmdapi.AlwaysExecInteractiveMode, terminal: true, interactive: true},
+		{mode: clientcmdapi.AlwaysExecInteractiveMode, terminal: false, wantErr: true},
+	}
+	for _, tc := range cases {
+		interactive, err := execInteractive(tc.mode, tc.terminal)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("mode %q terminal=%t: unexpected error %v", tc.mode, tc.terminal, err)
+		}
+		if interactive != tc.interactive {
+			t.Fatalf("mode %q terminal=%t: expected interactive=%t", tc.mode, tc.terminal, tc.interactive)
+		}
+	}
+}
+
+func TestExtractCertificateAuthority(t *testing.T) {
+	content := []byte(`apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    server: https://rancher.example.com/k8s/clusters/local
+    certificate-authority-data: LS0tLS1CRUdJTiBDRVJUSUZJQ0FURS0tLS0tCg==
+  name: local
+contexts:
+- context:
+    cluster: local
+    user: test
+  name: local
+current-context: local
+users:
+- name: test
+  user:
+    token: dummy
+`)
+	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := ExtractCertificateAuthority(clientConfig, info.Context)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(ca) != "-----BEGIN CERTIFICATE-----\n" {
+		t.Fatalf("unexpected CA data %q", string(ca))
+	}
+}
+
+func TestExtractCertificateAuthorityMissing(t *testing.T) {
+	content := []byte(fmt.Sprintf(sampleConfigTemplate, "byte-context", "byte-context"))
+	clientConfig, info, err := ResolveKubeconfigFromBytes(content, "inline", nil, "", rest.ImpersonationConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ca, err := ExtractCertificateAuthority(clientConfig, info.Context)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ca != nil {
+		t.Fatalf("expected no CA data, got %q", string(ca))
+	}
+}
+
+func TestListContextsAcrossFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	first := writeConfig(t, tempDir, "a.yaml", "prod-b")
+	second := writeConfig(t, tempDir, "b.yaml", "prod-a")
+	t.Setenv("KUBECONFIG", first+string(os.PathListSeparator)+second)
+
+	names, err := ListContexts("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"prod-a", "prod-b"}) {
+		t.Fatalf("unexpected contexts %v", names)
+	}
+}
+
+func TestSelectContexts(t *testing.T) {
+	available := []string{"dev", "prod-b", "prod-a", "rancher"}
+
+	selected, err := SelectContexts(available, []string{"rancher", "prod-b"}, "prod-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(selected, []string{"rancher", "prod-b", "prod-a"}) {
+		t.Fatalf("unexpected selection %v", selected)
+	}
+
+	var configErr *ConfigError
+	if _, err := SelectContexts(available, []string{"staging"}, ""); !errors.As(err, &configErr) || configErr.Kind != ErrContextNotFound {
+		t.Fatalf("expected context_not_found for unknown name, got %v", err)
+	}
+	if _, err := SelectContexts(available, nil, "qa-*"); !errors.As(err, &configErr) || configErr.Kind != ErrContextNotFound {
+		t.Fatalf("expected context_not_found for unmatched pattern, got %v", err)
+	}
+	if _, err := SelectContexts(available, nil, "prod-["); err == nil {
+		t.Fatalf("expected error for invalid pattern")
+	}
+}
+
 func writeConfig(t *testing.T, dir, name, context string) string {
 	t.Helper()
 	path := filepath.Join(dir, name)
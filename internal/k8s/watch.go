@@ -0,0 +1,128 @@
+package k8s
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	listersv1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// NodeWatch keeps an informer-backed view of the cluster's nodes.
+type NodeWatch struct {
+	lister  listersv1.NodeLister
+	changes chan struct{}
+}
+
+// WatchNodes starts a node informer and returns once its cache has synced.
+// The initial sync fails on the first list or watch error, or after the
+// client timeout; later errors go to onError while the informer retries.
+// The informer stops when ctx is done.
+func (c *Client) WatchNodes(ctx context.Context, selector labels.Selector, onError func(error)) (*NodeWatch, error) {
+	if selector == nil {
+		selector = labels.Everything()
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0, informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+		opts.LabelSelector = selector.String()
+		opts.Limit = c.pageSize
+	}))
+	informer := factory.Core().V1().Nodes()
+	watch := &NodeWatch{lister: informer.Lister(), changes: make(chan struct{}, 1)}
+	notify := func() {
+		select {
+		case watch.changes <- struct{}{}:
+		default:
+		}
+	}
+	_, err := informer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(any) { notify() },
+		UpdateFunc: func(any, any) { notify() },
+		DeleteFunc: func(any) { notify() },
+	})
+	if err != nil {
+		return nil, &APIError{Kind: ErrUnknown, Err: err}
+	}
+
+	syncTimeout := cmp.Or(c.timeout, DefaultTimeout)
+	syncCtx, cancelSync := context.WithTimeout(ctx, syncTimeout)
+	defer cancelSync()
+	var (
+		mu      sync.Mutex
+		syncErr error
+		synced  bool
+	)
+	// Without a handler the reflector only logs and retries, so an
+	// unreachable or forbidden cluster would never finish syncing.
+	err = informer.Informer().SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !synced {
+			if syncErr == nil {
+				syncErr = err
+			}
+			cancelSync()
+			return
+		}
+		if onError != nil {
+			onError(classifyK8sError(err))
+		}
+	})
+	if err != nil {
+		return nil, &APIError{Kind: ErrUnknown, Err: err}
+	}
+	stopCh := make(chan struct{})
+	stop := sync.OnceFunc(func() { close(stopCh) })
+	context.AfterFunc(ctx, stop)
+	factory.Start(stopCh)
+	for _, ok := range factory.WaitForCacheSync(syncCtx.Done()) {
+		if !ok {
+			stop()
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			mu.Lock()
+			err := syncErr
+			mu.Unlock()
+			if err != nil {
+				return nil, classifyK8sError(err)
+			}
+			return nil, &APIError{Kind: ErrClusterUnreachable, Err: fmt.Errorf("node informer did not sync within %s", syncTimeout)}
+		}
+	}
+	mu.Lock()
+	synced = true
+	mu.Unlock()
+	// Drain the notifications from the initial listing.
+	select {
+	case <-watch.changes:
+	default:
+	}
+	return watch, nil
+}
+
+// Changes signals, coalesced, that nodes were added, updated or deleted.
+func (w *NodeWatch) Changes() <-chan struct{} {
+	return w.changes
+}
+
+// Nodes returns the current nodes sorted by name.
+func (w *NodeWatch) Nodes() ([]types.K8sNode, error) {
+	items, err := w.lister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]types.K8sNode, 0, len(items))
+	for _, item := range items {
+		// Informer objects are shared; normalize a copy.
+		out = append(out, normalizeNode(*item.DeepCopy()))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
@@ -0,0 +1,65 @@
+package match
+
+import (
+	"sort"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+)
+
+type ClusterSummary struct {
+	Cluster        string
+	Nodes          int
+	Matched        int
+	Ambiguous      int
+	UnmatchedNodes int
+}
+
+// SummarizeClusters breaks a result down by the source cluster of each node.
+func SummarizeClusters(result Result, nodes []types.K8sNode) []ClusterSummary {
+	byCluster := map[string]*ClusterSummary{}
+	get := func(name string) *ClusterSummary {
+		summary, ok := byCluster[name]
+		if !ok {
+			summary = &ClusterSummary{Cluster: name}
+			byCluster[name] = summary
+		}
+		return summary
+	}
+
+	for _, node := range nodes {
+		get(node.Cluster).Nodes++
+	}
+	for _, entry := range result.Matches {
+		for _, cluster := range candidateClusters(entry.Candidates) {
+			get(cluster).Matched++
+		}
+	}
+	for _, entry := range result.Ambiguous {
+		for _, cluster := range candidateClusters(entry.Candidates) {
+			get(cluster).Ambiguous++
+		}
+	}
+	for _, node := range result.UnmatchedNodes {
+		get(node.Cluster).UnmatchedNodes++
+	}
+
+	out := make([]ClusterSummary, 0, len(byCluster))
+	for _, summary := range byCluster {
+		out = append(out, *summary)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cluster < out[j].Cluster })
+	return out
+}
+
+func candidateClusters(candidates []NodeMatch) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	for _, candidate := range candidates {
+		if _, ok := seen[candidate.Node.Cluster]; ok {
+			continue
+		}
+		seen[candidate.Node.Cluster] = struct{}{}
+		out = append(out, candidate.Node.Cluster)
+	}
+	return out
+}
@@ -0,0 +1,40 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+)
+
+func TestSummarizeClusters(t *testing.T) {
+	nodes := []types.K8sNode{
+		{Name: "node-1", Cluster: "alpha", InternalIPs: []string{"10.0.0.1"}},
+		{Name: "node-1", Cluster: "beta", InternalIPs: []string{"10.0.1.1"}},
+		{Name: "node-2", Cluster: "beta"},
+	}
+	hosts := []types.InventoryHost{
+		{ID: "host-a", IPs: []string{"10.0.0.1"}},
+		{ID: "host-b", IPs: []string{"10.0.1.1"}},
+		{ID: "host-c", Hostname: "orphan"},
+	}
+
+	result := Match(hosts, nodes)
+	if len(result.Matches) != 2 {
+		t.Fatalf("expected 2 matches across clusters, got %d", len(result.Matches))
+	}
+	if len(result.UnmatchedHosts) != 1 {
+		t.Fatalf("expected 1 host in no cluster, got %d", len(result.UnmatchedHosts))
+	}
+
+	summaries := SummarizeClusters(result, nodes)
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 cluster summaries, got %d", len(summaries))
+	}
+	alpha, beta := summaries[0], summaries[1]
+	if alpha.Cluster != "alpha" || alpha.Nodes != 1 || alpha.Matched != 1 || alpha.UnmatchedNodes != 0 {
+		t.Fatalf("unexpected alpha summary: %+v", alpha)
+	}
+	if beta.Cluster != "beta" || beta.Nodes != 2 || beta.Matched != 1 || beta.UnmatchedNodes != 1 {
+		t.Fatalf("unexpected beta summary: %+v", beta)
+	}
+}
@@ -0,0 +1,178 @@
+package match
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+)
+
+type EventType string
+
+const (
+	EventHostMatched    EventType = "host-matched"
+	EventHostAmbiguous  EventType = "host-ambiguous"
+	EventHostUnmatched  EventType = "host-unmatched"
+	EventHostChanged    EventType = "host-changed"
+	EventHostDowngraded EventType = "host-downgraded"
+	EventHostUpgraded   EventType = "host-upgraded"
+	EventHostRemoved    EventType = "host-removed"
+	EventNodeUnmatched  EventType = "node-unmatched"
+	EventNodeRemoved    EventType = "node-removed"
+)
+
+// Event is one change between two match results. Host is empty for node
+// events; Node is the matched node, or the first candidate when ambiguous.
+type Event struct {
+	Type           EventType
+	Host           types.InventoryHost
+	Node           types.K8sNode
+	Method         Method
+	Confidence     float64
+	PreviousNode   string
+	PreviousMethod Method
+}
+
+type hostState struct {
+	host   types.InventoryHost
+	status EventType
+	match  HostMatch
+	// nodes identifies the candidate nodes; names is their display form.
+	nodes string
+	names string
+}
+
+// Diff reports what changed from prev to next: hosts that became matched,
+// ambiguous or unmatched, moved to another node, had their match method
+// downgraded or upgraded, or left the inventory, and nodes that lost their
+// inventory host or disappeared. Diffing against an empty Result describes
+// next from scratch. Host events come first, then node events, each in a
+// stable order.
+func Diff(prev, next Result) []Event {
+	before := hostStates(prev)
+	after := hostStates(next)
+
+	var events []Event
+	for _, key := range sortedKeys(after) {
+		now := after[key]
+		was, existed := before[key]
+		event := Event{Host: now.host}
+		if now.status != EventHostUnmatched {
+			event.Node = now.match.Candidates[0].Node
+			event.Method = now.match.Method
+			event.Confidence = now.match.Confidence
+		}
+		if existed && was.status != EventHostUnmatched {
+			event.PreviousNode = was.names
+			event.PreviousMethod = was.match.Method
+		}
+		switch {
+		case !existed || was.status != now.status:
+			event.Type = now.status
+		case now.status == EventHostUnmatched:
+			continue
+		case was.nodes != now.nodes:
+			event.Type = EventHostChanged
+		case now.match.Confidence < was.match.Confidence:
+			event.Type = EventHostDowngraded
+		case now.match.Confidence > was.match.Confidence:
+			event.Type = EventHostUpgraded
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	for _, key := range sortedKeys(before) {
+		if _, ok := after[key]; ok {
+			continue
+		}
+		was := before[key]
+		event := Event{Type: EventHostRemoved, Host: was.host}
+		if was.status != EventHostUnmatched {
+			event.PreviousNode = was.names
+			event.PreviousMethod = was.match.Method
+		}
+		events = append(events, event)
+	}
+
+	prevNodes, prevUnmatched := nodeStates(prev)
+	nextNodes, nextUnmatched := nodeStates(next)
+	for _, key := range sortedKeys(nextUnmatched) {
+		if _, ok := prevUnmatched[key]; ok {
+			continue
+		}
+		events = append(events, Event{Type: EventNodeUnmatched, Node: nextUnmatched[key]})
+	}
+	for _, key := range sortedKeys(prevNodes) {
+		if _, ok := nextNodes[key]; ok {
+			continue
+		}
+		events = append(events, Event{Type: EventNodeRemoved, Node: prevNodes[key]})
+	}
+	return events
+}
+
+func hostStates(result Result) map[string]hostState {
+	states := make(map[string]hostState, len(result.Matches)+len(result.Ambiguous)+len(result.UnmatchedHosts))
+	add := func(entries []HostMatch, status EventType) {
+		for _, entry := range entries {
+			keys := make([]string, 0, len(entry.Candidates))
+			names := make([]string, 0, len(entry.Candidates))
+			for _, candidate := range entry.Candidates {
+				keys = append(keys, nodeKey(candidate.Node))
+				names = append(names, candidate.Node.Name)
+			}
+			sort.Strings(keys)
+			states[hostKey(entry.Host)] = hostState{
+				host:   entry.Host,
+				status: status,
+				match:  entry,
+				nodes:  strings.Join(keys, ","),
+				names:  strings.Join(names, ","),
+			}
+		}
+	}
+	add(result.Matches, EventHostMatched)
+	add(result.Ambiguous, EventHostAmbiguous)
+	for _, host := range result.UnmatchedHosts {
+		states[hostKey(host)] = hostState{host: host, status: EventHostUnmatched}
+	}
+	return states
+}
+
+// nodeStates returns every node present in result and the unmatched subset.
+func nodeStates(result Result) (map[string]types.K8sNode, map[string]types.K8sNode) {
+	all := map[string]types.K8sNode{}
+	unmatched := map[string]types.K8sNode{}
+	for _, entries := range [][]HostMatch{result.Matches, result.Ambiguous} {
+		for _, entry := range entries {
+			for _, candidate := range entry.Candidates {
+				all[nodeKey(candidate.Node)] = candidate.Node
+			}
+		}
+	}
+	for _, node := range result.UnmatchedNodes {
+		all[nodeKey(node)] = node
+		unmatched[nodeKey(node)] = node
+	}
+	return all, unmatched
+}
+
+func hostKey(host types.InventoryHost) string {
+	if host.UID != "" {
+		return host.UID
+	}
+	if host.ID != "" {
+		return host.ID
+	}
+	return host.Namespace + "/" + host.MachineName + "/" + host.Hostname
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,102 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+)
+
+func TestDiffFromEmpty(t *testing.T) {
+	nodes := []types.K8sNode{
+		{Name: "node-1", UID: "1", MachineID: "mid-1"},
+		{Name: "node-2", UID: "2"},
+	}
+	hosts := []types.InventoryHost{
+		{ID: "host-a", MachineID: "mid-1"},
+		{ID: "host-b", Hostname: "host-b"},
+	}
+
+	events := Diff(Result{}, Match(hosts, nodes))
+	want := []EventType{EventHostMatched, EventHostUnmatched, EventNodeUnmatched}
+	assertEventTypes(t, events, want)
+	if events[0].Node.Name != "node-1" || events[0].Method != MethodMachineID {
+		t.Fatalf("unexpected matched event: %+v", events[0])
+	}
+	if events[2].Node.Name != "node-2" {
+		t.Fatalf("expected node-2 to be reported unmatched, got %s", events[2].Node.Name)
+	}
+}
+
+func TestDiffNoChanges(t *testing.T) {
+	nodes := []types.K8sNode{{Name: "node-1", UID: "1", MachineID: "mid-1"}}
+	hosts := []types.InventoryHost{{ID: "host-a", MachineID: "mid-1"}}
+
+	if events := Diff(Match(hosts, nodes), Match(hosts, nodes)); len(events) != 0 {
+		t.Fatalf("expected no events, got %+v", events)
+	}
+}
+
+func TestDiffTransitions(t *testing.T) {
+	before := Match(
+		[]types.InventoryHost{
+			{ID: "host-a", MachineID: "mid-a", IPs: []string{"10.0.0.1"}},
+			{ID: "host-b", IPs: []string{"10.0.0.2"}},
+			{ID: "host-c", IPs: []string{"10.0.0.3"}},
+			{ID: "host-d", Hostname: "node-d"},
+		},
+		[]types.K8sNode{
+			{Name: "node-a", UID: "a", MachineID: "mid-a", InternalIPs: []string{"10.0.0.1"}},
+			{Name: "node-b", UID: "b", InternalIPs: []string{"10.0.0.2"}},
+			{Name: "node-c", UID: "c", InternalIPs: []string{"10.0.0.3"}},
+			{Name: "node-d", UID: "d"},
+		},
+	)
+	after := Match(
+		[]types.InventoryHost{
+			{ID: "host-a", MachineID: "mid-a", IPs: []string{"10.0.0.1"}},
+			{ID: "host-b", IPs: []string{"10.0.0.2"}},
+			{ID: "host-c", IPs: []string{"10.0.0.3"}},
+		},
+		[]types.K8sNode{
+			// node-a lost its machine ID: still matched, but by IP.
+			{Name: "node-a", UID: "a", InternalIPs: []string{"10.0.0.1"}},
+			// host-b now points at a replacement node.
+			{Name: "node-b2", UID: "b2", InternalIPs: []string{"10.0.0.2"}},
+			// node-c is still there but no host claims it anymore.
+			{Name: "node-c", UID: "c"},
+			{Name: "node-d", UID: "d"},
+		},
+	)
+
+	events := Diff(before, after)
+	want := []EventType{EventHostDowngraded, EventHostChanged, EventHostUnmatched, EventHostRemoved, EventNodeUnmatched, EventNodeUnmatched, EventNodeRemoved}
+	assertEventTypes(t, events, want)
+
+	if events[0].PreviousMethod != MethodMachineID || events[0].Method != MethodInternalIP {
+		t.Fatalf("expected machine-id -> internal-ip downgrade, got %+v", events[0])
+	}
+	if events[1].PreviousNode != "node-b" || events[1].Node.Name != "node-b2" {
+		t.Fatalf("expected host-b to move from node-b to node-b2, got %+v", events[1])
+	}
+	if events[2].Host.ID != "host-c" || events[2].PreviousNode != "node-c" {
+		t.Fatalf("expected host-c to lose node-c, got %+v", events[2])
+	}
+	if events[3].Host.ID != "host-d" || events[3].PreviousNode != "node-d" {
+		t.Fatalf("expected host-d to be removed, got %+v", events[3])
+	}
+	if events[4].Node.Name != "node-c" || events[5].Node.Name != "node-d" || events[6].Node.Name != "node-b" {
+		t.Fatalf("unexpected node events: %+v", events[4:])
+	}
+}
+
+func assertEventTypes(t *testing.T, events []Event, want []EventType) {
+	t.Helper()
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, event := range events {
+		if event.Type != want[i] {
+			t.Fatalf("event %d: expected %s, got %s", i, want[i], event.Type)
+		}
+	}
+}
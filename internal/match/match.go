@@ -3,6 +3,7 @@ package match
 import (
 	"fmt"
 	"net"
+	"slices"
 	"sort"
 	"strings"
 
@@ -60,39 +61,99 @@ type nodeIndex struct {
 }
 
 func Match(hosts []types.InventoryHost, nodes []types.K8sNode) Result {
-	index := buildIndex(nodes)
-	result := Result{}
-	nodeSeen := make(map[string]struct{})
+	matcher := NewMatcher(nodes)
+	matcher.Add(hosts...)
+	return matcher.Result()
+}
+
+// Matcher matches hosts against a fixed node set incrementally, so hosts can
+// be added page by page as they arrive, and removed or replaced one at a time
+// while the nodes stay the same.
+type Matcher struct {
+	nodes []types.K8sNode
+	index nodeIndex
+	// nodeSeen counts the hosts claiming each node.
+	nodeSeen map[string]int
+	result   Result
+}
 
+func NewMatcher(nodes []types.K8sNode) *Matcher {
+	return &Matcher{
+		nodes:    nodes,
+		index:    buildIndex(nodes),
+		nodeSeen: make(map[string]int),
+	}
+}
+
+func (m *Matcher) Add(hosts ...types.InventoryHost) {
 	for _, host := range hosts {
-		if matches, ok := matchByMachineID(host, index); ok {
-			result.addMatch(host, matches, nodeSeen)
-			continue
-		}
-		if matches, ok := matchByProviderID(host, index); ok {
-			result.addMatch(host, matches, nodeSeen)
-			continue
-		}
-		if matches, ok := matchByInternalIP(host, index); ok {
-			result.addMatch(host, matches, nodeSeen)
-			continue
-		}
-		if matches, ok := matchByExternalIP(host, index); ok {
-			result.addMatch(host, matches, nodeSeen)
+		m.add(host)
+	}
+}
+
+func (m *Matcher) add(host types.InventoryHost) {
+	if matches, ok := matchByMachineID(host, m.index); ok {
+		m.result.addMatch(host, matches, m.nodeSeen)
+		return
+	}
+	if matches, ok := matchByProviderID(host, m.index); ok {
+		m.result.addMatch(host, matches, m.nodeSeen)
+		return
+	}
+	if matches, ok := matchByInternalIP(host, m.index); ok {
+		m.result.addMatch(host, matches, m.nodeSeen)
+		return
+	}
+	if matches, ok := matchByExternalIP(host, m.index); ok {
+		m.result.addMatch(host, matches, m.nodeSeen)
+		return
+	}
+	if matches, ok := matchByHostname(host, m.index); ok {
+		m.result.addMatch(host, matches, m.nodeSeen)
+		return
+	}
+	m.result.UnmatchedHosts = append(m.result.UnmatchedHosts, host)
+}
+
+// Remove drops host, identified like Diff does, and releases the nodes it
+// claimed. It reports whether the host had been added.
+func (m *Matcher) Remove(host types.InventoryHost) bool {
+	key := hostKey(host)
+	for _, entries := range []*[]HostMatch{&m.result.Matches, &m.result.Ambiguous} {
+		i := slices.IndexFunc(*entries, func(entry HostMatch) bool { return hostKey(entry.Host) == key })
+		if i < 0 {
 			continue
 		}
-		if matches, ok := matchByHostname(host, index); ok {
-			result.addMatch(host, matches, nodeSeen)
-			continue
+		for _, candidate := range (*entries)[i].Candidates {
+			node := nodeKey(candidate.Node)
+			if m.nodeSeen[node]--; m.nodeSeen[node] <= 0 {
+				delete(m.nodeSeen, node)
+			}
 		}
-		result.UnmatchedHosts = append(result.UnmatchedHosts, host)
+		*entries = slices.Delete(*entries, i, i+1)
+		return true
+	}
+	i := slices.IndexFunc(m.result.UnmatchedHosts, func(existing types.InventoryHost) bool { return hostKey(existing) == key })
+	if i < 0 {
+		return false
 	}
+	m.result.UnmatchedHosts = slices.Delete(m.result.UnmatchedHosts, i, i+1)
+	return true
+}
 
-	result.UnmatchedNodes = append(result.UnmatchedNodes, collectUnmatched(nodes, nodeSeen)...)
+// Result returns the matches so far; nodes not claimed by any host added yet
+// are reported as unmatched.
+func (m *Matcher) Result() Result {
+	result := Result{
+		Matches:        append([]HostMatch(nil), m.result.Matches...),
+		Ambiguous:      append([]HostMatch(nil), m.result.Ambiguous...),
+		UnmatchedHosts: append([]types.InventoryHost(nil), m.result.UnmatchedHosts...),
+	}
+	result.UnmatchedNodes = collectUnmatched(m.nodes, m.nodeSeen)
 	return result
 }
 
-func (r *Result) addMatch(host types.InventoryHost, matches []NodeMatch, nodeSeen map[string]struct{}) {
+func (r *Result) addMatch(host types.InventoryHost, matches []NodeMatch, nodeSeen map[string]int) {
 	if len(matches) == 1 {
 		match := HostMatch{
 			Host:       host,
@@ -112,11 +173,11 @@ func (r *Result) addMatch(host types.InventoryHost, matches []NodeMatch, nodeSee
 	}
 
 	for _, candidate := range matches {
-		nodeSeen[nodeKey(candidate.Node)] = struct{}{}
+		nodeSeen[nodeKey(candidate.Node)]++
 	}
 }
 
-func collectUnmatched(nodes []types.K8sNode, nodeSeen map[string]struct{}) []types.K8sNode {
+func collectUnmatched(nodes []types.K8sNode, nodeSeen map[string]int) []types.K8sNode {
 	var unmatched []types.K8sNode
 	for _, node := range nodes {
 		if _, ok := nodeSeen[nodeKey(node)]; !ok {
@@ -402,6 +463,9 @@ func nodeKey(node types.K8sNode) string {
 	if node.UID != "" {
 		return node.UID
 	}
+	if node.Cluster != "" {
+		return node.Cluster + "/" + node.Name
+	}
 	return node.Name
 }
 
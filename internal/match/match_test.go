@@ -84,3 +84,63 @@ func TestMatchMachineNameFromNodeLabels(t *testing.T) {
 		t.Fatalf("expected machine-name match, got %s", result.Matches[0].Method)
 	}
 }
+
+func TestMatcherIncremental(t *testing.T) {
+	nodes := []types.K8sNode{
+		{Name: "node-1", UID: "1", InternalIPs: []string{"10.0.0.1"}},
+		{Name: "node-2", UID: "2", InternalIPs: []string{"10.0.0.2"}},
+	}
+	matcher := NewMatcher(nodes)
+	matcher.Add(types.InventoryHost{ID: "host-a", IPs: []string{"10.0.0.1"}})
+
+	partial := matcher.Result()
+	if len(partial.Matches) != 1 || len(partial.UnmatchedNodes) != 1 {
+		t.Fatalf("expected 1 match and 1 unmatched node, got %d/%d", len(partial.Matches), len(partial.UnmatchedNodes))
+	}
+
+	matcher.Add(types.InventoryHost{ID: "host-b", IPs: []string{"10.0.0.2"}})
+	final := matcher.Result()
+	if len(final.Matches) != 2 || len(final.UnmatchedNodes) != 0 {
+		t.Fatalf("expected 2 matches and no unmatched nodes, got %d/%d", len(final.Matches), len(final.UnmatchedNodes))
+	}
+	if len(partial.Matches) != 1 {
+		t.Fatalf("earlier result should not change after more hosts are added")
+	}
+}
+
+func TestMatcherRemoveReleasesNodes(t *testing.T) {
+	nodes := []types.K8sNode{
+		{Name: "node-1", UID: "1", InternalIPs: []string{"10.0.0.1"}},
+		{Name: "node-2", UID: "2", InternalIPs: []string{"10.0.0.2"}},
+	}
+	hostA := types.InventoryHost{ID: "host-a", IPs: []string{"10.0.0.1"}}
+	hostB := types.InventoryHost{ID: "host-b", IPs: []string{"10.0.0.1"}}
+	matcher := NewMatcher(nodes)
+	matcher.Add(hostA, hostB, types.InventoryHost{ID: "host-c"})
+
+	if !matcher.Remove(hostA) {
+		t.Fatalf("expected host-a to be removed")
+	}
+	result := matcher.Result()
+	if len(result.Matches) != 1 || result.Matches[0].Host.ID != "host-b" {
+		t.Fatalf("expected only host-b matched, got %+v", result.Matches)
+	}
+	if len(result.UnmatchedNodes) != 1 || result.UnmatchedNodes[0].Name != "node-2" {
+		t.Fatalf("node-1 is still claimed by host-b, got unmatched %+v", result.UnmatchedNodes)
+	}
+
+	matcher.Remove(hostB)
+	matcher.Remove(types.InventoryHost{ID: "host-c"})
+	if matcher.Remove(hostB) {
+		t.Fatalf("removing a host twice should report false")
+	}
+	hostB.IPs = []string{"10.0.0.2"}
+	matcher.Add(hostB)
+	result = matcher.Result()
+	if len(result.Matches) != 1 || result.Matches[0].Candidates[0].Node.Name != "node-2" || len(result.UnmatchedHosts) != 0 {
+		t.Fatalf("expected host-b re-matched to node-2 alone, got %+v", result)
+	}
+	if len(result.UnmatchedNodes) != 1 || result.UnmatchedNodes[0].Name != "node-1" {
+		t.Fatalf("expected node-1 released, got %+v", result.UnmatchedNodes)
+	}
+}
@@ -0,0 +1,102 @@
+package output
+
+import (
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/rancher"
+	"github.com/pterm/pterm"
+)
+
+type CacheEntry struct {
+	RancherURL     string     `json:"rancherURL" yaml:"rancherURL"`
+	ClusterID      string     `json:"clusterID" yaml:"clusterID"`
+	ClusterName    string     `json:"clusterName,omitempty" yaml:"clusterName,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" yaml:"createdAt"`
+	Age            string     `json:"age" yaml:"age"`
+	TokenExpiresAt *time.Time `json:"tokenExpiresAt,omitempty" yaml:"tokenExpiresAt,omitempty"`
+	Expired        bool       `json:"expired" yaml:"expired"`
+	Entry          string     `json:"entry" yaml:"entry"`
+	Error          string     `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
+func RenderCacheEntries(entries []rancher.KubeconfigCacheEntry, ttl time.Duration, mode Mode) error {
+	now := time.Now()
+	out := make([]CacheEntry, 0, len(entries))
+	for _, entry := range entries {
+		out = append(out, buildCacheEntry(entry, ttl, now))
+	}
+	switch mode {
+	case ModeJSON:
+		return EmitJSON(out)
+	case ModeYAML:
+		return EmitYAML(out)
+	default:
+		InitStyles()
+		rows := [][]string{{"Rancher URL", "Cluster ID", "Cluster", "Age", "Token Expires", "Status"}}
+		for _, entry := range out {
+			if entry.Error != "" {
+				rows = append(rows, []string{"-", "-", entry.Entry, "-", "-", pterm.FgRed.Sprint("unreadable: " + entry.Error)})
+				continue
+			}
+			rows = append(rows, []string{entry.RancherURL, entry.ClusterID, valueOrDash(entry.ClusterName), entry.Age, formatExpiry(entry.TokenExpiresAt), cacheStatus(entry.Expired)})
+		}
+		return pterm.DefaultTable.WithHasHeader().WithData(rows).Render()
+	}
+}
+
+func RenderCacheEntry(entry rancher.KubeconfigCacheEntry, ttl time.Duration, mode Mode) error {
+	out := buildCacheEntry(entry, ttl, time.Now())
+	switch mode {
+	case ModeJSON:
+		return EmitJSON(out)
+	case ModeYAML:
+		return EmitYAML(out)
+	default:
+		InitStyles()
+		rows := [][]string{
+			{"Rancher URL", out.RancherURL},
+			{"Cluster ID", out.ClusterID},
+			{"Cluster", valueOrDash(out.ClusterName)},
+			{"Created", out.CreatedAt.Format(time.RFC3339)},
+			{"Age", out.Age},
+			{"Token Expires", formatExpiry(out.TokenExpiresAt)},
+			{"Status", cacheStatus(out.Expired)},
+			{"Entry", out.Entry},
+		}
+		return pterm.DefaultTable.WithData(rows).Render()
+	}
+}
+
+func buildCacheEntry(entry rancher.KubeconfigCacheEntry, ttl time.Duration, now time.Time) CacheEntry {
+	if entry.Err != nil {
+		return CacheEntry{Entry: entry.Name, Error: entry.Err.Error()}
+	}
+	out := CacheEntry{
+		RancherURL:  entry.RancherURL,
+		ClusterID:   entry.ClusterID,
+		ClusterName: entry.ClusterName,
+		CreatedAt:   entry.CreatedAt,
+		Age:         entry.Age(now).Truncate(time.Second).String(),
+		Expired:     entry.Expired(ttl, now),
+		Entry:       entry.Name,
+	}
+	if !entry.TokenExpiresAt.IsZero() {
+		expires := entry.TokenExpiresAt
+		out.TokenExpiresAt = &expires
+	}
+	return out
+}
+
+func formatExpiry(expires *time.Time) string {
+	if expires == nil {
+		return "-"
+	}
+	return expires.Format(time.RFC3339)
+}
+
+func cacheStatus(expired bool) string {
+	if expired {
+		return pterm.FgYellow.Sprint("expired")
+	}
+	return pterm.FgGreen.Sprint("valid")
+}
@@ -0,0 +1,55 @@
+package output
+
+import (
+	"github.com/pterm/pterm"
+)
+
+type CheckStatus string
+
+const (
+	CheckPass CheckStatus = "pass"
+	CheckWarn CheckStatus = "warn"
+	CheckFail CheckStatus = "fail"
+	CheckSkip CheckStatus = "skip"
+)
+
+// Check is the outcome of one doctor preflight check.
+type Check struct {
+	Name   string      `json:"name" yaml:"name"`
+	Status CheckStatus `json:"status" yaml:"status"`
+	Detail string      `json:"detail,omitempty" yaml:"detail,omitempty"`
+	Hint   string      `json:"hint,omitempty" yaml:"hint,omitempty"`
+}
+
+func RenderChecks(checks []Check, mode Mode) error {
+	switch mode {
+	case ModeJSON:
+		return EmitJSON(checks)
+	case ModeYAML:
+		return EmitYAML(checks)
+	default:
+		InitStyles()
+		rows := [][]string{{"Check", "Status", "Detail"}}
+		for _, check := range checks {
+			detail := check.Detail
+			if check.Hint != "" {
+				detail += "\n" + pterm.FgGray.Sprint("hint: "+check.Hint)
+			}
+			rows = append(rows, []string{check.Name, checkBadge(check.Status), detail})
+		}
+		return styledTable(rows).Render()
+	}
+}
+
+func checkBadge(status CheckStatus) string {
+	switch status {
+	case CheckPass:
+		return statusBadge("PASS", pterm.BgGreen, pterm.FgBlack)
+	case CheckWarn:
+		return statusBadge("WARN", pterm.BgYellow, pterm.FgBlack)
+	case CheckFail:
+		return statusBadge("FAIL", pterm.BgRed, pterm.FgBlack)
+	default:
+		return statusBadge("SKIP", pterm.BgGray, pterm.FgBlack)
+	}
+}
@@ -0,0 +1,27 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ErrorOutput is the machine-readable error envelope.
+type ErrorOutput struct {
+	Code      int               `json:"code"`
+	Kind      string            `json:"kind"`
+	Source    string            `json:"source,omitempty"`
+	Message   string            `json:"message"`
+	Endpoint  string            `json:"endpoint,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+	Hint      string            `json:"hint,omitempty"`
+	Partial   bool              `json:"partial"`
+	Failures  map[string]string `json:"failures,omitempty"`
+}
+
+func RenderErrorJSON(w io.Writer, out ErrorOutput) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Error ErrorOutput `json:"error"`
+	}{out})
+}
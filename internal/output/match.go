@@ -2,6 +2,7 @@ package output
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
@@ -16,6 +17,8 @@ type MatchOptions struct {
 	Wide          bool
 	Mode          Mode
 	ClusterName   string
+	Clusters      []match.ClusterSummary
+	ClusterErrors map[string]string
 }
 
 type MatchSummary struct {
@@ -25,9 +28,19 @@ type MatchSummary struct {
 	UnmatchedNodes int `json:"unmatchedNodes" yaml:"unmatchedNodes"`
 }
 
+type ClusterSummary struct {
+	Cluster        string `json:"cluster" yaml:"cluster"`
+	Nodes          int    `json:"nodes" yaml:"nodes"`
+	Matched        int    `json:"matched" yaml:"matched"`
+	Ambiguous      int    `json:"ambiguous" yaml:"ambiguous"`
+	UnmatchedNodes int    `json:"unmatchedNodes" yaml:"unmatchedNodes"`
+	Error          string `json:"error,omitempty" yaml:"error,omitempty"`
+}
+
 type MatchOutput struct {
 	Cluster        string                `json:"cluster,omitempty" yaml:"cluster,omitempty"`
 	Summary        MatchSummary          `json:"summary" yaml:"summary"`
+	Clusters       []ClusterSummary      `json:"clusters,omitempty" yaml:"clusters,omitempty"`
 	Matches        []matchPayload        `json:"matches" yaml:"matches"`
 	Ambiguous      []matchPayload        `json:"ambiguous" yaml:"ambiguous"`
 	UnmatchedHosts []types.InventoryHost `json:"unmatchedHosts" yaml:"unmatchedHosts"`
@@ -58,26 +71,65 @@ func RenderMatch(result match.Result, opts MatchOptions) error {
 
 	switch opts.Mode {
 	case ModeJSON:
-		return EmitJSON(buildMatchOutput(result, summary, opts.Explain, opts.ClusterName))
+		return EmitJSON(buildMatchOutput(result, summary, opts))
 	case ModeYAML:
-		return EmitYAML(buildMatchOutput(result, summary, opts.Explain, opts.ClusterName))
+		return EmitYAML(buildMatchOutput(result, summary, opts))
 	default:
 		return renderMatchTable(result, summary, opts)
 	}
 }
 
-func buildMatchOutput(result match.Result, summary MatchSummary, explain bool, clusterName string) MatchOutput {
+func buildMatchOutput(result match.Result, summary MatchSummary, opts MatchOptions) MatchOutput {
 	payload := MatchOutput{
-		Cluster:        clusterName,
+		Cluster:        opts.ClusterName,
 		Summary:        summary,
+		Clusters:       clusterSummaries(opts),
 		UnmatchedHosts: result.UnmatchedHosts,
 		UnmatchedNodes: result.UnmatchedNodes,
 	}
-	payload.Matches = renderMatchPayload(result.Matches, explain)
-	payload.Ambiguous = renderMatchPayload(result.Ambiguous, explain)
+	payload.Matches = renderMatchPayload(result.Matches, opts.Explain)
+	payload.Ambiguous = renderMatchPayload(result.Ambiguous, opts.Explain)
 	return payload
 }
 
+func clusterSummaries(opts MatchOptions) []ClusterSummary {
+	if len(opts.Clusters) == 0 && len(opts.ClusterErrors) == 0 {
+		return nil
+	}
+	out := make([]ClusterSummary, 0, len(opts.Clusters)+len(opts.ClusterErrors))
+	for _, summary := range opts.Clusters {
+		out = append(out, ClusterSummary{
+			Cluster:        summary.Cluster,
+			Nodes:          summary.Nodes,
+			Matched:        summary.Matched,
+			Ambiguous:      summary.Ambiguous,
+			UnmatchedNodes: summary.UnmatchedNodes,
+			Error:          opts.ClusterErrors[summary.Cluster],
+		})
+	}
+	for cluster, message := range opts.ClusterErrors {
+		if hasClusterSummary(opts.Clusters, cluster) {
+			continue
+		}
+		out = append(out, ClusterSummary{Cluster: cluster, Error: message})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Cluster < out[j].Cluster })
+	return out
+}
+
+func hasClusterSummary(summaries []match.ClusterSummary, cluster string) bool {
+	for _, summary := range summaries {
+		if summary.Cluster == cluster {
+			return true
+		}
+	}
+	return false
+}
+
+func multiCluster(opts MatchOptions) bool {
+	return len(opts.Clusters) > 0 || len(opts.ClusterErrors) > 0
+}
+
 func renderMatchPayload(matches []match.HostMatch, explain bool) []matchPayload {
 	out := make([]matchPayload, 0, len(matches))
 	for _, entry := range matches {
@@ -104,9 +156,15 @@ func renderMatchPayload(matches []match.HostMatch, explain bool) []matchPayload
 
 func renderMatchTable(result match.Result, summary MatchSummary, opts MatchOptions) error {
 	InitStyles()
-	renderSummaryBox(summary, opts.ClusterName)
+	renderSummaryBox(summary, opts)
 	renderLegend()
 
+	if multiCluster(opts) {
+		if err := renderClustersTable(clusterSummaries(opts)); err != nil {
+			return err
+		}
+	}
+
 	hasMatches := len(result.Matches)+len(result.Ambiguous) > 0
 	if hasMatches {
 		if err := renderMatchesTable(result, opts); err != nil {
@@ -153,6 +211,9 @@ func renderMatchesTable(result match.Result, opts MatchOptions) error {
 	sectionTitle("Matches")
 	rows := [][]string{}
 	columns := []string{"Status", "Elemental Host", "Rancher Machine", "K8s Node", "Match Method", "Confidence", "K8s InternalIP"}
+	if multiCluster(opts) {
+		columns = append(columns, "Cluster")
+	}
 	if opts.Wide {
 		columns = append(columns, "K8s ExternalIP", "K8s ProviderID", "K8s MachineID")
 	}
@@ -174,6 +235,9 @@ func renderMatchesTable(result match.Result, opts MatchOptions) error {
 			fmt.Sprintf("%.0f%%", confidence*100),
 			k8s.NodePrimaryInternalIP(node),
 		}
+		if multiCluster(opts) {
+			row = append(row, valueOrDash(node.Cluster))
+		}
 		if opts.Wide {
 			row = append(row, valueOrDash(k8s.NodePrimaryExternalIP(node)), valueOrDash(node.ProviderID), valueOrDash(node.MachineID))
 		}
@@ -199,7 +263,11 @@ func renderMatchesTable(result match.Result, opts MatchOptions) error {
 }
 
 func renderUnmatchedHostsTable(hosts []types.InventoryHost, opts MatchOptions) error {
-	sectionTitle("Unmatched Hosts")
+	if multiCluster(opts) {
+		sectionTitle("Hosts In No Cluster")
+	} else {
+		sectionTitle("Unmatched Hosts")
+	}
 	columns := []string{"Elemental Host", "Inventory ID", "Hostname", "IPs"}
 	if opts.Wide {
 		columns = append(columns, "Namespace", "Inventory UID", "Host MachineID", "Host SystemUUID", "Host ProviderID")
@@ -225,6 +293,9 @@ func renderUnmatchedHostsTable(hosts []types.InventoryHost, opts MatchOptions) e
 		}
 		if opts.Explain {
 			reason := "no Kubernetes node match"
+			if multiCluster(opts) {
+				reason = "no node match in any cluster"
+			}
 			if host.MachineName == "" && host.Hostname == "" && host.ID == "" && host.UID == "" &&
 				len(host.IPs) == 0 && host.MachineID == "" && host.SystemUUID == "" && host.ProviderID == "" {
 				reason = "inventory record missing identifiers"
@@ -241,6 +312,9 @@ func renderUnmatchedHostsTable(hosts []types.InventoryHost, opts MatchOptions) e
 func renderUnmatchedNodesTable(nodes []types.K8sNode, opts MatchOptions) error {
 	sectionTitle("Unmatched Nodes")
 	columns := []string{"Rancher Machine", "K8s Node", "K8s InternalIP"}
+	if multiCluster(opts) {
+		columns = append(columns, "Cluster")
+	}
 	if opts.Wide {
 		columns = append(columns, "K8s ExternalIP", "K8s ProviderID", "K8s MachineID")
 	}
@@ -255,6 +329,9 @@ func renderUnmatchedNodesTable(nodes []types.K8sNode, opts MatchOptions) error {
 			node.Name,
 			valueOrDash(k8s.NodePrimaryInternalIP(node)),
 		}
+		if multiCluster(opts) {
+			row = append(row, valueOrDash(node.Cluster))
+		}
 		if opts.Wide {
 			row = append(row, valueOrDash(k8s.NodePrimaryExternalIP(node)), valueOrDash(node.ProviderID), valueOrDash(node.MachineID))
 		}
@@ -282,10 +359,13 @@ func valueOrDash(value string) string {
 	return value
 }
 
-func renderSummaryBox(summary MatchSummary, clusterName string) {
+func renderSummaryBox(summary MatchSummary, opts MatchOptions) {
 	lines := []string{}
-	if clusterName != "" {
-		lines = append(lines, pterm.FgGray.Sprint("Cluster: ")+pterm.FgLightCyan.Sprint(clusterName))
+	if multiCluster(opts) {
+		count := len(clusterSummaries(opts))
+		lines = append(lines, pterm.FgGray.Sprint("Clusters: ")+pterm.FgLightCyan.Sprint(count))
+	} else if opts.ClusterName != "" {
+		lines = append(lines, pterm.FgGray.Sprint("Cluster: ")+pterm.FgLightCyan.Sprint(opts.ClusterName))
 	}
 
 	stats := []string{
@@ -301,6 +381,26 @@ func renderSummaryBox(summary MatchSummary, clusterName string) {
 	box.Println(strings.Join(lines, "\n"))
 }
 
+func renderClustersTable(summaries []ClusterSummary) error {
+	sectionTitle("Clusters")
+	rows := [][]string{{"Cluster", "Nodes", "Matched", "Ambiguous", "Unmatched Nodes", "Status"}}
+	for _, summary := range summaries {
+		status := statusBadge("OK", pterm.BgGreen, pterm.FgBlack)
+		if summary.Error != "" {
+			status = statusBadge("FAILED", pterm.BgRed, pterm.FgBlack) + " " + summary.Error
+		}
+		rows = append(rows, []string{
+			summary.Cluster,
+			fmt.Sprintf("%d", summary.Nodes),
+			fmt.Sprintf("%d", summary.Matched),
+			fmt.Sprintf("%d", summary.Ambiguous),
+			fmt.Sprintf("%d", summary.UnmatchedNodes),
+			status,
+		})
+	}
+	return styledTable(rows).Render()
+}
+
 func metricBadge(label string, value int, color pterm.Color) string {
 	style := pterm.NewStyle(color, pterm.Bold)
 	return style.Sprintf("%s: %d", label, value)
@@ -3,10 +3,12 @@ package output
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/goldyfruit/elemental-node-mapper/internal/k8s"
 	"github.com/goldyfruit/elemental-node-mapper/internal/types"
 	"github.com/pterm/pterm"
+	"k8s.io/apimachinery/pkg/util/duration"
 )
 
 type NodesOptions struct {
@@ -31,7 +33,7 @@ func renderNodesTable(nodes []types.K8sNode, opts NodesOptions) error {
 	InitStyles()
 	columns := []string{"Node Name", "InternalIP"}
 	if opts.Wide {
-		columns = append(columns, "ExternalIP", "ProviderID", "MachineID")
+		columns = append(columns, "Status", "Roles", "Age", "Version", "ExternalIP", "ProviderID", "MachineID", "OS Image", "Kernel", "Runtime")
 	}
 	if len(opts.LabelKeys) > 0 {
 		for _, key := range opts.LabelKeys {
@@ -42,11 +44,23 @@ func renderNodesTable(nodes []types.K8sNode, opts NodesOptions) error {
 		columns = append(columns, "Labels")
 	}
 
+	now := time.Now()
 	rows := make([][]string, 0, len(nodes))
 	for _, node := range nodes {
 		row := []string{node.Name, k8s.NodePrimaryInternalIP(node)}
 		if opts.Wide {
-			row = append(row, k8s.NodePrimaryExternalIP(node), node.ProviderID, node.MachineID)
+			row = append(row,
+				nodeStatus(node),
+				valueOrDash(strings.Join(node.Roles, ",")),
+				nodeAge(node, now),
+				valueOrDash(node.KubeletVersion),
+				k8s.NodePrimaryExternalIP(node),
+				node.ProviderID,
+				node.MachineID,
+				valueOrDash(node.OSImage),
+				valueOrDash(node.KernelVersion),
+				valueOrDash(node.ContainerRuntimeVersion),
+			)
 		}
 		if len(opts.LabelKeys) > 0 {
 			for _, key := range opts.LabelKeys {
@@ -63,6 +77,21 @@ func renderNodesTable(nodes []types.K8sNode, opts NodesOptions) error {
 	return table.Render()
 }
 
+func nodeStatus(node types.K8sNode) string {
+	status := k8s.NodeStatus(node)
+	if node.Ready {
+		return pterm.FgGreen.Sprint(status)
+	}
+	return pterm.FgRed.Sprint(status)
+}
+
+func nodeAge(node types.K8sNode, now time.Time) string {
+	if node.CreatedAt.IsZero() {
+		return "-"
+	}
+	return duration.HumanDuration(now.Sub(node.CreatedAt))
+}
+
 func formatLabels(labels map[string]string) string {
 	if len(labels) == 0 {
 		return ""
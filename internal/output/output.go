@@ -17,6 +17,9 @@ const (
 	ModeYAML  Mode = "yaml"
 )
 
+// Modes lists the modes ParseMode accepts.
+var Modes = []Mode{ModeTable, ModeJSON, ModeYAML}
+
 func ParseMode(raw string) (Mode, error) {
 	switch raw {
 	case "", string(ModeTable):
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/match"
+	"github.com/pterm/pterm"
+)
+
+type WatchEvent struct {
+	Time           time.Time       `json:"time" yaml:"time"`
+	Type           match.EventType `json:"type" yaml:"type"`
+	Host           string          `json:"host,omitempty" yaml:"host,omitempty"`
+	Node           string          `json:"node,omitempty" yaml:"node,omitempty"`
+	Cluster        string          `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	Method         match.Method    `json:"method,omitempty" yaml:"method,omitempty"`
+	Confidence     float64         `json:"confidence,omitempty" yaml:"confidence,omitempty"`
+	PreviousNode   string          `json:"previousNode,omitempty" yaml:"previousNode,omitempty"`
+	PreviousMethod match.Method    `json:"previousMethod,omitempty" yaml:"previousMethod,omitempty"`
+}
+
+// RenderWatchEvents prints one line per event, or one JSON object per line
+// (NDJSON) in JSON mode.
+func RenderWatchEvents(events []match.Event, at time.Time, mode Mode) error {
+	encoder := json.NewEncoder(os.Stdout)
+	for _, event := range events {
+		out := WatchEvent{
+			Time:           at.UTC(),
+			Type:           event.Type,
+			Node:           event.Node.Name,
+			Cluster:        event.Node.Cluster,
+			Method:         event.Method,
+			Confidence:     event.Confidence,
+			PreviousNode:   event.PreviousNode,
+			PreviousMethod: event.PreviousMethod,
+		}
+		if event.Type != match.EventNodeUnmatched && event.Type != match.EventNodeRemoved {
+			out.Host = hostLabel(event.Host)
+		}
+		if mode == ModeJSON {
+			if err := encoder.Encode(out); err != nil {
+				return err
+			}
+			continue
+		}
+		pterm.Println(formatWatchEvent(out))
+	}
+	return nil
+}
+
+func formatWatchEvent(event WatchEvent) string {
+	stamp := pterm.FgGray.Sprint(event.Time.Local().Format(time.TimeOnly))
+	badge := watchBadge(event.Type)
+	switch event.Type {
+	case match.EventNodeUnmatched:
+		return fmt.Sprintf("%s %s node %s has no inventory host", stamp, badge, event.Node)
+	case match.EventNodeRemoved:
+		return fmt.Sprintf("%s %s node %s left the cluster", stamp, badge, event.Node)
+	case match.EventHostUnmatched:
+		if event.PreviousNode != "" {
+			return fmt.Sprintf("%s %s host %s lost node %s", stamp, badge, event.Host, event.PreviousNode)
+		}
+		return fmt.Sprintf("%s %s host %s matches no node", stamp, badge, event.Host)
+	case match.EventHostRemoved:
+		return fmt.Sprintf("%s %s host %s left the inventory", stamp, badge, event.Host)
+	case match.EventHostChanged:
+		return fmt.Sprintf("%s %s host %s moved %s -> %s (%s)", stamp, badge, event.Host, event.PreviousNode, event.Node, event.Method)
+	case match.EventHostDowngraded, match.EventHostUpgraded:
+		return fmt.Sprintf("%s %s host %s -> %s now by %s (was %s)", stamp, badge, event.Host, event.Node, event.Method, event.PreviousMethod)
+	default:
+		return fmt.Sprintf("%s %s host %s -> %s (%s %.2f)", stamp, badge, event.Host, event.Node, event.Method, event.Confidence)
+	}
+}
+
+func watchBadge(eventType match.EventType) string {
+	switch eventType {
+	case match.EventHostMatched:
+		return statusBadge("MATCHED", pterm.BgGreen, pterm.FgBlack)
+	case match.EventHostUpgraded:
+		return statusBadge("UPGRADED", pterm.BgGreen, pterm.FgBlack)
+	case match.EventHostAmbiguous:
+		return statusBadge("AMBIG", pterm.BgYellow, pterm.FgBlack)
+	case match.EventHostChanged:
+		return statusBadge("CHANGED", pterm.BgCyan, pterm.FgBlack)
+	case match.EventHostDowngraded:
+		return statusBadge("DOWNGRADED", pterm.BgYellow, pterm.FgBlack)
+	case match.EventHostRemoved, match.EventNodeRemoved:
+		return statusBadge("REMOVED", pterm.BgGray, pterm.FgBlack)
+	default:
+		return statusBadge("UNMATCHED", pterm.BgRed, pterm.FgBlack)
+	}
+}
@@ -0,0 +1,142 @@
+package rancher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var authProviderPaths = map[string]string{
+	"local":           "localProviders/local",
+	"openldap":        "openLdapProviders/openldap",
+	"activedirectory": "activeDirectoryProviders/activedirectory",
+	"freeipa":         "freeIpaProviders/freeipa",
+}
+
+type TokenRequest struct {
+	Description string
+	TTL         time.Duration
+	// ClusterID scopes the token to a single downstream cluster when set.
+	ClusterID string
+}
+
+type Token struct {
+	Name      string
+	Token     string
+	ExpiresAt time.Time
+}
+
+func AuthProviders() []string {
+	providers := make([]string, 0, len(authProviderPaths))
+	for provider := range authProviderPaths {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	return providers
+}
+
+// Login authenticates against a Rancher auth provider and returns a session
+// token. The token is meant to mint a scoped API token and then be discarded.
+func (s *Session) Login(ctx context.Context, provider, username, password string) (string, error) {
+	path, ok := authProviderPaths[strings.ToLower(provider)]
+	if !ok {
+		return "", fmt.Errorf("unsupported auth provider %q (supported: %s)", provider, strings.Join(AuthProviders(), ", "))
+	}
+	target := withAction(s.baseURL.JoinPath("/v3-public", path), "login")
+	body := map[string]any{
+		"username":     username,
+		"password":     password,
+		"responseType": "json",
+		"description":  "elemental-node-map login",
+	}
+	var payload map[string]any
+	if err := s.clientWithToken(target, "").doJSONRequest(ctx, http.MethodPost, target, body, &payload); err != nil {
+		return "", err
+	}
+	token := firstString(payload, "token")
+	if token == "" {
+		return "", fmt.Errorf("rancher login response missing token")
+	}
+	return token, nil
+}
+
+// WithToken returns a session sharing the transport, settings and retry
+// count of s but authenticating with token.
+func (s *Session) WithToken(token string) *Session {
+	clone := *s
+	clone.token = token
+	return &clone
+}
+
+// CreateToken mints an API token owned by the session's user.
+func (s *Session) CreateToken(ctx context.Context, req TokenRequest) (Token, error) {
+	target := s.baseURL.JoinPath("/v3/tokens")
+	body := map[string]any{
+		"type":        "token",
+		"description": req.Description,
+	}
+	if req.TTL > 0 {
+		body["ttl"] = req.TTL.Milliseconds()
+	}
+	if req.ClusterID != "" {
+		body["clusterId"] = req.ClusterID
+	}
+	var payload map[string]any
+	if err := s.client(target).doJSONRequest(ctx, http.MethodPost, target, body, &payload); err != nil {
+		return Token{}, err
+	}
+	token := tokenFromPayload(payload)
+	if token.Token == "" {
+		return Token{}, fmt.Errorf("rancher token response missing token")
+	}
+	return token, nil
+}
+
+func tokenFromPayload(payload map[string]any) Token {
+	token := Token{
+		Name:  firstString(payload, "name", "id"),
+		Token: firstString(payload, "token"),
+	}
+	if expires := firstString(payload, "expiresAt"); expires != "" {
+		if parsed, err := time.Parse(time.RFC3339, expires); err == nil {
+			token.ExpiresAt = parsed
+		}
+	}
+	return token
+}
+
+// GetToken looks up an API token by name, e.g. the kubeconfig-user token
+// embedded in a generated kubeconfig.
+func (s *Session) GetToken(ctx context.Context, name string) (Token, error) {
+	if name == "" {
+		return Token{}, fmt.Errorf("token name is required")
+	}
+	target := s.baseURL.JoinPath("/v3/tokens", name)
+	client := s.client(target)
+	var payload map[string]any
+	err := client.withRetry(ctx, target.String(), func() error {
+		payload = nil
+		return client.doJSONRequest(ctx, http.MethodGet, target, nil, &payload)
+	})
+	if err != nil {
+		return Token{}, err
+	}
+	return tokenFromPayload(payload), nil
+}
+
+// Logout invalidates the token the session authenticates with.
+func (s *Session) Logout(ctx context.Context) error {
+	target := withAction(s.baseURL.JoinPath("/v3/tokens"), "logout")
+	return s.client(target).doJSONRequest(ctx, http.MethodPost, target, nil, nil)
+}
+
+func withAction(target *url.URL, action string) *url.URL {
+	q := target.Query()
+	q.Set("action", action)
+	target.RawQuery = q.Encode()
+	return target
+}
@@ -0,0 +1,81 @@
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginCreateTokenLogout(t *testing.T) {
+	var created map[string]any
+	loggedOut := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v3-public/localProviders/local" && r.URL.Query().Get("action") == "login":
+			var body map[string]any
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["username"] != "admin" || body["password"] != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"token": "token-login:abc"})
+		case r.URL.Path == "/v3/tokens" && r.URL.Query().Get("action") == "logout":
+			if r.Header.Get("Authorization") != "Bearer token-login:abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			loggedOut = true
+		case r.URL.Path == "/v3/tokens":
+			if r.Header.Get("Authorization") != "Bearer token-login:abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_ = json.NewDecoder(r.Body).Decode(&created)
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"name":      "token-xyz",
+				"token":     "token-xyz:secret",
+				"expiresAt": "2030-01-02T03:04:05Z",
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := session.Login(ctx, "local", "admin", "wrong"); err == nil {
+		t.Fatalf("expected login failure")
+	}
+	if _, err := session.Login(ctx, "github", "admin", "secret"); err == nil {
+		t.Fatalf("expected unsupported provider error")
+	}
+	loginToken, err := session.Login(ctx, "local", "admin", "secret")
+	if err != nil {
+		t.Fatalf("unexpected login error: %v", err)
+	}
+
+	authed := session.WithToken(loginToken)
+	token, err := authed.CreateToken(ctx, TokenRequest{Description: "test", TTL: time.Hour, ClusterID: "c-1"})
+	if err != nil {
+		t.Fatalf("unexpected token error: %v", err)
+	}
+	if token.Name != "token-xyz" || token.Token != "token-xyz:secret" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+	if !token.ExpiresAt.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected expiry %s", token.ExpiresAt)
+	}
+	if created["ttl"] != float64(3600000) || created["clusterId"] != "c-1" {
+		t.Fatalf("unexpected token request %v", created)
+	}
+	if err := authed.Logout(ctx); err != nil || !loggedOut {
+		t.Fatalf("expected logout, err=%v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package rancher
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/fileutil"
+)
+
+const (
+	// CacheKeyEnv holds a passphrase (or 64 hex characters) used to encrypt the cache.
+	CacheKeyEnv = "ELEMENTAL_NODE_MAP_CACHE_KEY"
+	// CacheKeyFileEnv points at a file holding the key; DefaultCacheKeyFile is used otherwise.
+	CacheKeyFileEnv = "ELEMENTAL_NODE_MAP_CACHE_KEY_FILE"
+)
+
+var encryptedCacheMagic = []byte("ENMCACHE1\n")
+
+// Cache is a directory of small files shared by concurrent invocations.
+// Writes go through a temp file and rename, every access holds an advisory
+// lock on the directory, and entries are sealed with AES-GCM when a key is set.
+type Cache struct {
+	dir  string
+	aead cipher.AEAD
+}
+
+// NewCache opens a cache in dir. A nil key stores entries in clear text.
+func NewCache(dir string, key []byte) (*Cache, error) {
+	cache := &Cache{dir: dir}
+	if key != nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("cache key must be 32 bytes, got %d", len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		cache.aead, err = cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return cache, nil
+}
+
+// OpenCache opens the default cache directory, encrypted when a key is
+// configured through CacheKeyEnv, CacheKeyFileEnv or DefaultCacheKeyFile.
+func OpenCache() (*Cache, error) {
+	key, err := loadCacheKey()
+	if err != nil {
+		return nil, err
+	}
+	return NewCache(DefaultCacheDir(), key)
+}
+
+func DefaultCacheDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil || base == "" {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "elemental-node-map")
+}
+
+func DefaultCacheKeyFile() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config dir: %w", err)
+	}
+	return filepath.Join(base, "elemental-node-map", "cache.key"), nil
+}
+
+// GenerateCacheKey writes a new random key to path, refusing to overwrite one.
+func GenerateCacheKey(path string) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// CacheName derives a stable file name from parts.
+func CacheName(prefix, ext string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "|")))
+	return prefix + hex.EncodeToString(sum[:16]) + ext
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+func (c *Cache) Encrypted() bool {
+	return c.aead != nil
+}
+
+// Get returns the entry stored under name; ok is false when it does not exist.
+func (c *Cache) Get(name string) ([]byte, bool, error) {
+	var data []byte
+	err := c.withLock(false, func() error {
+		var err error
+		data, err = os.ReadFile(filepath.Join(c.dir, name))
+		return err
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if c.aead != nil && !bytes.HasPrefix(data, encryptedCacheMagic) {
+		// With a key set, clear-text entries are misses: they may have been
+		// planted, and dropping them makes the next write seal a fresh one.
+		return nil, false, c.dropClearText(name)
+	}
+	data, err = c.open(name, data)
+	if err != nil {
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+// dropClearText deletes name unless it was sealed since it was read.
+func (c *Cache) dropClearText(name string) error {
+	return c.withLock(true, func() error {
+		path := filepath.Join(c.dir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if bytes.HasPrefix(data, encryptedCacheMagic) {
+			return nil
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+func (c *Cache) Put(name string, data []byte) error {
+	sealed, err := c.seal(name, data)
+	if err != nil {
+		return err
+	}
+	return c.withLock(true, func() error {
+		return fileutil.WriteFileAtomic(filepath.Join(c.dir, name), sealed)
+	})
+}
+
+func (c *Cache) Delete(name string) error {
+	return c.withLock(true, func() error {
+		if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	})
+}
+
+// List returns the names of entries starting with prefix, sorted.
+func (c *Cache) List(prefix string) ([]string, error) {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (c *Cache) seal(name string, data []byte) ([]byte, error) {
+	if c.aead == nil {
+		return data, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, encryptedCacheMagic...)
+	out = append(out, nonce...)
+	return c.aead.Seal(out, nonce, data, []byte(name)), nil
+}
+
+func (c *Cache) open(name string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedCacheMagic) {
+		if c.aead != nil {
+			return nil, fmt.Errorf("cache entry %s is not encrypted", name)
+		}
+		return data, nil
+	}
+	if c.aead == nil {
+		return nil, fmt.Errorf("cache entry %s is encrypted; set %s or %s", name, CacheKeyEnv, CacheKeyFileEnv)
+	}
+	data = data[len(encryptedCacheMagic):]
+	if len(data) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("cache entry %s is truncated", name)
+	}
+	nonce, sealed := data[:c.aead.NonceSize()], data[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, []byte(name))
+	if err != nil {
+		return nil, fmt.Errorf("cache entry %s cannot be decrypted with the configured key", name)
+	}
+	return plain, nil
+}
+
+func (c *Cache) withLock(exclusive bool, fn func() error) error {
+	return fileutil.WithDirLock(c.dir, exclusive, fn)
+}
+
+func loadCacheKey() ([]byte, error) {
+	if raw := os.Getenv(CacheKeyEnv); raw != "" {
+		return parseCacheKey(raw), nil
+	}
+	path := os.Getenv(CacheKeyFileEnv)
+	explicit := path != ""
+	if !explicit {
+		var err error
+		path, err = DefaultCacheKeyFile()
+		if err != nil {
+			return nil, nil
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read cache key file: %w", err)
+	}
+	raw := strings.TrimSpace(string(data))
+	if raw == "" {
+		return nil, fmt.Errorf("cache key file %s is empty", path)
+	}
+	return parseCacheKey(raw), nil
+}
+
+// parseCacheKey accepts a hex-encoded 32-byte key or derives one from a passphrase.
+func parseCacheKey(raw string) []byte {
+	if len(raw) == 64 {
+		if key, err := hex.DecodeString(raw); err == nil {
+			return key
+		}
+	}
+	sum := sha256.Sum256([]byte(raw))
+	return sum[:]
+}
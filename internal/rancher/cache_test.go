@@ -0,0 +1,161 @@
+package rancher
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheEncryptionAtRest(t *testing.T) {
+	dir := t.TempDir()
+	key := parseCacheKey("correct horse battery staple")
+	cache, err := NewCache(dir, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secret := []byte("token: kubeconfig-user-abc:secret")
+	if err := cache.Put("entry.json", secret); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(dir, "entry.json"))
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if bytes.Contains(raw, []byte("secret")) {
+		t.Fatalf("expected ciphertext on disk, got %q", raw)
+	}
+
+	data, ok, err := cache.Get("entry.json")
+	if err != nil || !ok || !bytes.Equal(data, secret) {
+		t.Fatalf("unexpected round trip %q ok=%v err=%v", data, ok, err)
+	}
+
+	plain, _ := NewCache(dir, nil)
+	if _, _, err := plain.Get("entry.json"); err == nil || !strings.Contains(err.Error(), CacheKeyEnv) {
+		t.Fatalf("expected missing key error, got %v", err)
+	}
+	other, _ := NewCache(dir, parseCacheKey("another passphrase"))
+	if _, _, err := other.Get("entry.json"); err == nil {
+		t.Fatalf("expected decryption failure with another key")
+	}
+
+	// An entry is bound to its name, so renaming files does not swap secrets.
+	if err := os.Rename(filepath.Join(dir, "entry.json"), filepath.Join(dir, "moved.json")); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if _, _, err := cache.Get("moved.json"); err == nil {
+		t.Fatalf("expected renamed entry to be rejected")
+	}
+}
+
+func TestCacheDropsClearTextWithKey(t *testing.T) {
+	dir := t.TempDir()
+	plain, _ := NewCache(dir, nil)
+	if err := plain.Put("entry.json", []byte("clear")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	encrypted, _ := NewCache(dir, parseCacheKey("key"))
+	data, ok, err := encrypted.Get("entry.json")
+	if err != nil || ok {
+		t.Fatalf("expected clear-text entry to be a miss, got %q ok=%v err=%v", data, ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "entry.json")); !os.IsNotExist(err) {
+		t.Fatalf("expected clear-text entry to be deleted, stat err=%v", err)
+	}
+}
+
+func TestCacheConcurrentWrites(t *testing.T) {
+	dir := t.TempDir()
+	cache, _ := NewCache(dir, parseCacheKey("key"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cache.Put("shared.json", []byte(fmt.Sprintf("writer-%02d", i))); err != nil {
+				t.Errorf("put: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	data, ok, err := cache.Get("shared.json")
+	if err != nil || !ok || !strings.HasPrefix(string(data), "writer-") || len(data) != len("writer-00") {
+		t.Fatalf("expected one complete write to win, got %q ok=%v err=%v", data, ok, err)
+	}
+	names, err := cache.List("")
+	if err != nil || len(names) != 1 {
+		t.Fatalf("expected no leftover temp files, got %v err=%v", names, err)
+	}
+}
+
+func TestLoadCacheKey(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(CacheKeyEnv, "")
+	t.Setenv(CacheKeyFileEnv, "")
+
+	key, err := loadCacheKey()
+	if err != nil || key != nil {
+		t.Fatalf("expected no key by default, got %x err=%v", key, err)
+	}
+
+	path, err := DefaultCacheKeyFile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := GenerateCacheKey(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := GenerateCacheKey(path); err == nil {
+		t.Fatalf("expected existing key file to be kept")
+	}
+	key, err = loadCacheKey()
+	if err != nil || len(key) != 32 {
+		t.Fatalf("expected key from default key file, got %x err=%v", key, err)
+	}
+
+	t.Setenv(CacheKeyFileEnv, filepath.Join(t.TempDir(), "missing.key"))
+	if _, err := loadCacheKey(); err == nil {
+		t.Fatalf("expected error for missing explicit key file")
+	}
+	t.Setenv(CacheKeyEnv, "passphrase")
+	key, err = loadCacheKey()
+	if err != nil || !bytes.Equal(key, parseCacheKey("passphrase")) {
+		t.Fatalf("expected env key to win, got %x err=%v", key, err)
+	}
+}
+
+func TestResultCacheTTL(t *testing.T) {
+	cache, _ := NewCache(t.TempDir(), nil)
+	name := ResultCacheName("https://rancher.example.com", "inventories", "env=prod")
+	if err := cache.SaveResult(name, []Cluster{{ID: "c-1", Name: "prod"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var clusters []Cluster
+	age, ok, err := cache.LoadResult(name, time.Minute, &clusters)
+	if err != nil || !ok {
+		t.Fatalf("expected cached result, ok=%v err=%v", ok, err)
+	}
+	if age < 0 || age > time.Minute || len(clusters) != 1 || clusters[0].ID != "c-1" {
+		t.Fatalf("unexpected result %+v age=%s", clusters, age)
+	}
+	if _, ok, _ := cache.LoadResult(name, time.Nanosecond, &clusters); ok {
+		t.Fatalf("expected result past TTL to be ignored")
+	}
+	if _, ok, _ := cache.LoadResult(ResultCacheName("other"), time.Minute, &clusters); ok {
+		t.Fatalf("expected miss for another key")
+	}
+
+	removed, err := cache.PurgeResults()
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one result purged, got %d err=%v", removed, err)
+	}
+}
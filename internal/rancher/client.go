@@ -1,89 +1,62 @@
 package rancher
 
 import (
+	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/goldyfruit/elemental-node-mapper/internal/types"
 )
 
-const (
-	defaultTimeout = 20 * time.Second
-	defaultRetries = 3
-)
+const defaultTimeout = 20 * time.Second
 
 type Client struct {
-	baseURL    *url.URL
-	token      string
-	httpClient *http.Client
-}
-
-type APIError struct {
-	StatusCode int
-	Err        error
-}
-
-func (e *APIError) Error() string {
-	if e.StatusCode == http.StatusUnauthorized {
-		return "rancher authentication failed"
-	}
-	if e.StatusCode == http.StatusForbidden {
-		return "rancher authorization failed"
-	}
-	if e.StatusCode >= 400 {
-		return fmt.Sprintf("rancher API error (status %d)", e.StatusCode)
-	}
-	return fmt.Sprintf("rancher API error: %v", e.Err)
-}
-
-func (e *APIError) Unwrap() error {
-	return e.Err
+	baseURL     *url.URL
+	token       string
+	httpClient  *http.Client
+	retry       RetryPolicy
+	onRetry     func(RetryEvent)
+	retries     *atomic.Int64
+	pageSize    int
+	impersonate Impersonation
 }
 
-func NewClient(rawURL, token string, insecureSkipVerify bool) (*Client, error) {
-	if rawURL == "" {
-		return nil, fmt.Errorf("rancher URL is required")
-	}
-	parsed, err := url.Parse(rawURL)
+func (c *Client) ListInventoryHosts(ctx context.Context, opts InventoryOptions) ([]types.InventoryHost, error) {
+	var hosts []types.InventoryHost
+	err := c.StreamInventoryHosts(ctx, opts, func(page []types.InventoryHost) error {
+		hosts = append(hosts, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("invalid rancher URL: %w", err)
-	}
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecureSkipVerify},
-	}
-	return &Client{
-		baseURL: parsed,
-		token:   token,
-		httpClient: &http.Client{
-			Timeout:   defaultTimeout,
-			Transport: transport,
-		},
-	}, nil
+		return nil, err
+	}
+	return hosts, nil
 }
 
-func (c *Client) ListInventoryHosts(ctx context.Context) ([]types.InventoryHost, error) {
-	var hosts []types.InventoryHost
-	nextURL := c.withLimit(c.baseURL, 200)
-
-	for nextURL != nil {
-		page, err := c.fetchPage(ctx, nextURL)
-		if err != nil {
-			return nil, err
-		}
+// StreamInventoryHosts calls fn with each page of hosts as soon as it is
+// normalized, while the next page is already being fetched.
+func (c *Client) StreamInventoryHosts(ctx context.Context, opts InventoryOptions, fn func([]types.InventoryHost) error) error {
+	emitted := false
+	handle := func(page listResponse) error {
+		emitted = true
+		hosts := make([]types.InventoryHost, 0, len(page.Data))
 		for _, raw := range page.Data {
 			hosts = append(hosts, normalizeHost(raw))
 		}
-		nextURL = page.NextURL(c.baseURL)
+		return fn(filterInventoryHosts(hosts, opts))
 	}
-
-	return hosts, nil
+	err := c.walkPages(ctx, inventoryListURL(c.baseURL, opts, true), handle)
+	if err != nil && !emitted && opts.hasSelector() && isUnsupportedQuery(err) {
+		err = c.walkPages(ctx, inventoryListURL(c.baseURL, opts, false), handle)
+	}
+	return err
 }
 
 type listResponse struct {
@@ -107,23 +80,16 @@ func (r listResponse) NextURL(base *url.URL) *url.URL {
 }
 
 func (c *Client) fetchPage(ctx context.Context, target *url.URL) (listResponse, error) {
-	var lastErr error
-	for attempt := 0; attempt < defaultRetries; attempt++ {
-		resp, err := c.doRequest(ctx, target)
-		if err != nil {
-			lastErr = err
-			if shouldRetry(err) {
-				time.Sleep(backoff(attempt))
-				continue
-			}
-			return listResponse{}, err
-		}
-		return resp, nil
-	}
-	if lastErr == nil {
-		lastErr = errors.New("rancher request failed")
+	var resp listResponse
+	err := c.withRetry(ctx, target.String(), func() error {
+		var err error
+		resp, err = c.doRequest(ctx, target)
+		return err
+	})
+	if err != nil {
+		return listResponse{}, err
 	}
-	return listResponse{}, lastErr
+	return resp, nil
 }
 
 func (c *Client) doRequest(ctx context.Context, target *url.URL) (listResponse, error) {
@@ -136,51 +102,58 @@ func (c *Client) doRequest(ctx context.Context, target *url.URL) (listResponse,
 	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "elemental-node-map/1.0")
+	c.impersonate.apply(req.Header)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return listResponse{}, err
+		return listResponse{}, transportError(req, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
-		return listResponse{}, &APIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("status %d", resp.StatusCode)}
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return listResponse{}, &APIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("status %d", resp.StatusCode)}
+	if err := statusError(resp); err != nil {
+		return listResponse{}, err
 	}
 
 	var payload listResponse
 	decoder := json.NewDecoder(resp.Body)
 	decoder.UseNumber()
 	if err := decoder.Decode(&payload); err != nil {
-		return listResponse{}, &APIError{StatusCode: resp.StatusCode, Err: err}
+		return listResponse{}, decodeError(resp, err)
 	}
 	return payload, nil
 }
 
-func (c *Client) doJSONRequest(ctx context.Context, method string, target *url.URL, out any) error {
-	req, err := http.NewRequestWithContext(ctx, method, target.String(), nil)
+func (c *Client) doJSONRequest(ctx context.Context, method string, target *url.URL, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), reader)
 	if err != nil {
 		return err
 	}
 	if c.token != "" {
 		req.Header.Set("Authorization", "Bearer "+c.token)
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("User-Agent", "elemental-node-map/1.0")
+	c.impersonate.apply(req.Header)
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return err
+		return transportError(req, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
-		return &APIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("status %d", resp.StatusCode)}
-	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return &APIError{StatusCode: resp.StatusCode, Err: fmt.Errorf("status %d", resp.StatusCode)}
+	if err := statusError(resp); err != nil {
+		return err
 	}
 
 	if out == nil {
@@ -189,7 +162,7 @@ func (c *Client) doJSONRequest(ctx context.Context, method string, target *url.U
 	decoder := json.NewDecoder(resp.Body)
 	decoder.UseNumber()
 	if err := decoder.Decode(out); err != nil {
-		return &APIError{StatusCode: resp.StatusCode, Err: err}
+		return decodeError(resp, err)
 	}
 	return nil
 }
@@ -230,21 +203,3 @@ func resolveURL(base *url.URL, next string) *url.URL {
 	clone.RawQuery = parsed.RawQuery
 	return &clone
 }
-
-func shouldRetry(err error) bool {
-	var apiErr *APIError
-	if errors.As(err, &apiErr) {
-		return apiErr.StatusCode >= 500
-	}
-	return true
-}
-
-func backoff(attempt int) time.Duration {
-	if attempt <= 0 {
-		return 250 * time.Millisecond
-	}
-	if attempt == 1 {
-		return 600 * time.Millisecond
-	}
-	return 1200 * time.Millisecond
-}
@@ -0,0 +1,248 @@
+package rancher
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type ErrorKind string
+
+const (
+	ErrBadURL                ErrorKind = "bad_url"
+	ErrCRDNotInstalled       ErrorKind = "crd_not_installed"
+	ErrAuthFailed            ErrorKind = "auth_failed"
+	ErrTokenExpired          ErrorKind = "token_expired"
+	ErrForbidden             ErrorKind = "forbidden"
+	ErrTLSFailure            ErrorKind = "tls_failure"
+	ErrClusterNotFound       ErrorKind = "cluster_not_found"
+	ErrKubeconfigUnavailable ErrorKind = "kubeconfig_generation_disabled"
+	ErrUnreachable           ErrorKind = "unreachable"
+	ErrRateLimited           ErrorKind = "rate_limited"
+	ErrServer                ErrorKind = "server_error"
+	ErrUnknown               ErrorKind = "unknown"
+)
+
+type APIError struct {
+	Kind       ErrorKind
+	StatusCode int
+	// Message is the error message returned by Rancher, if any.
+	Message    string
+	Endpoint   string
+	RequestID  string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	var text string
+	switch e.Kind {
+	case ErrAuthFailed:
+		text = "rancher authentication failed"
+	case ErrTokenExpired:
+		text = "rancher token expired"
+	case ErrForbidden:
+		text = "rancher authorization failed"
+	case ErrBadURL:
+		text = "rancher endpoint not found"
+	case ErrCRDNotInstalled:
+		text = "rancher resource type not available"
+	case ErrClusterNotFound:
+		text = "rancher cluster not found"
+	case ErrKubeconfigUnavailable:
+		text = "rancher kubeconfig generation unavailable"
+	case ErrTLSFailure:
+		text = "rancher TLS verification failed"
+	case ErrUnreachable:
+		text = "rancher unreachable"
+	case ErrRateLimited:
+		text = "rancher rate limit exceeded"
+	default:
+		if e.StatusCode >= 400 {
+			text = fmt.Sprintf("rancher API error (status %d)", e.StatusCode)
+		} else {
+			text = "rancher API error"
+		}
+	}
+	if e.Kind == ErrServer {
+		text = fmt.Sprintf("rancher server error (status %d)", e.StatusCode)
+	}
+
+	switch {
+	case e.Message != "":
+		text += ": " + e.Message
+	case e.StatusCode == 0 && e.Err != nil:
+		text += ": " + e.Err.Error()
+	}
+
+	var details []string
+	if e.Endpoint != "" {
+		details = append(details, "endpoint="+e.Endpoint)
+	}
+	if e.RequestID != "" {
+		details = append(details, "request-id="+e.RequestID)
+	}
+	if len(details) > 0 {
+		text += " (" + strings.Join(details, ", ") + ")"
+	}
+	return text
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Hint suggests what the user should fix for this kind of error.
+func (e *APIError) Hint() string {
+	switch e.Kind {
+	case ErrBadURL:
+		return "check that --rancher-url points at the Rancher server, e.g. https://rancher.example.com"
+	case ErrCRDNotInstalled:
+		return "the resource type is not served by Rancher; install the Elemental operator (and Rancher Turtles/CAPI for machines) or fix --rancher-url"
+	case ErrAuthFailed:
+		return "check --rancher-token/RANCHER_TOKEN or run `elemental-node-map login`"
+	case ErrTokenExpired:
+		return "the Rancher token has expired; run `elemental-node-map login` or create a new API key"
+	case ErrForbidden:
+		return "the token's user lacks permission for this resource; ask a Rancher admin for access or use a token that is not scoped to another cluster"
+	case ErrTLSFailure:
+		return "trust the Rancher CA with --rancher-ca-file (or --insecure-skip-tls-verify for testing)"
+	case ErrClusterNotFound:
+		return "check --rancher-cluster; it accepts the cluster name or ID shown in Rancher"
+	case ErrKubeconfigUnavailable:
+		return "Rancher cannot generate a kubeconfig for this cluster; pass the downstream cluster with --kubeconfig instead"
+	case ErrUnreachable:
+		return "check DNS, network connectivity and proxy settings (--rancher-proxy, HTTPS_PROXY, NO_PROXY)"
+	case ErrRateLimited:
+		return "Rancher is throttling requests; retry later or raise --rancher-retries"
+	case ErrServer:
+		return "Rancher returned a server error; check the Rancher server logs for the request"
+	default:
+		return ""
+	}
+}
+
+type rancherErrorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Endpoint:   endpointOf(resp.Request),
+		RequestID:  requestID(resp.Header),
+		Err:        fmt.Errorf("status %d", resp.StatusCode),
+	}
+	var body rancherErrorBody
+	if data, err := io.ReadAll(io.LimitReader(resp.Body, 4096)); err == nil {
+		if json.Unmarshal(data, &body) == nil {
+			apiErr.Message = strings.TrimSpace(body.Message)
+		}
+	}
+	apiErr.Kind = classifyStatus(resp.StatusCode, resp.Request, body)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		apiErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	}
+	return apiErr
+}
+
+func classifyStatus(status int, req *http.Request, body rancherErrorBody) ErrorKind {
+	generateKubeconfig := false
+	path := ""
+	if req != nil && req.URL != nil {
+		generateKubeconfig = req.URL.Query().Get("action") == "generateKubeconfig"
+		path = strings.ToLower(req.URL.Path)
+	}
+	detail := strings.ToLower(body.Code + " " + body.Message)
+
+	switch {
+	case status == http.StatusUnauthorized:
+		if strings.Contains(detail, "expired") {
+			return ErrTokenExpired
+		}
+		return ErrAuthFailed
+	case status == http.StatusForbidden:
+		return ErrForbidden
+	case generateKubeconfig && (status == http.StatusMethodNotAllowed || status == http.StatusUnprocessableEntity):
+		return ErrKubeconfigUnavailable
+	case generateKubeconfig && status == http.StatusNotFound:
+		if strings.Contains(detail, "action") {
+			return ErrKubeconfigUnavailable
+		}
+		return ErrClusterNotFound
+	case status == http.StatusNotFound && strings.Contains(path, "/v1/"):
+		return ErrCRDNotInstalled
+	case status == http.StatusNotFound:
+		return ErrBadURL
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status >= 500:
+		return ErrServer
+	default:
+		return ErrUnknown
+	}
+}
+
+// transportError classifies a failure to reach Rancher at all.
+func transportError(req *http.Request, err error) error {
+	if req.Context().Err() != nil {
+		return err
+	}
+	kind := ErrUnreachable
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		verifyErr        *tls.CertificateVerificationError
+		recordErr        tls.RecordHeaderError
+	)
+	switch {
+	case errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &invalidCert),
+		errors.As(err, &verifyErr), errors.As(err, &recordErr):
+		kind = ErrTLSFailure
+	case strings.Contains(err.Error(), "unsupported protocol scheme"):
+		kind = ErrBadURL
+	}
+	return &APIError{Kind: kind, Endpoint: endpointOf(req), Err: err}
+}
+
+// decodeError reports a 2xx response that is not the JSON Rancher sends,
+// typically an HTML page from a proxy or the Rancher UI.
+func decodeError(resp *http.Response, err error) error {
+	return &APIError{
+		Kind:       ErrBadURL,
+		StatusCode: resp.StatusCode,
+		Message:    "response is not Rancher API JSON",
+		Endpoint:   endpointOf(resp.Request),
+		RequestID:  requestID(resp.Header),
+		Err:        err,
+	}
+}
+
+func endpointOf(req *http.Request) string {
+	if req == nil || req.URL == nil {
+		return ""
+	}
+	endpoint := url.URL{Scheme: req.URL.Scheme, Host: req.URL.Host, Path: req.URL.Path, RawQuery: req.URL.RawQuery}
+	return endpoint.String()
+}
+
+func requestID(header http.Header) string {
+	for _, name := range []string{"X-Api-Request-Id", "X-Request-Id", "X-Correlation-Id"} {
+		if value := header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
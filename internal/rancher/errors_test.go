@@ -0,0 +1,122 @@
+package rancher
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusErrorClassification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Api-Request-Id", "req-42")
+		switch {
+		case r.Header.Get("Authorization") == "Bearer expired":
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"type":"error","code":"Unauthorized","message":"token is expired"}`))
+		case r.URL.Query().Get("action") == "generateKubeconfig" && strings.HasSuffix(r.URL.Path, "/c-local"):
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"type":"error","code":"NotFound","message":"invalid action: generateKubeconfig"}`))
+		case r.URL.Query().Get("action") == "generateKubeconfig":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"type":"error","code":"NotFound","message":"clusters.management.cattle.io \"c-missing\" not found"}`))
+		case strings.HasPrefix(r.URL.Path, "/v1/"):
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/v3/clusters":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write([]byte("<html>rancher ui</html>"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	cases := []struct {
+		name string
+		call func() error
+		kind ErrorKind
+	}{
+		{"expired token", func() error {
+			_, err := session.WithToken("expired").Machines().ListMachines(ctx)
+			return err
+		}, ErrTokenExpired},
+		{"missing CRD", func() error {
+			_, err := session.Inventories().ListInventoryHosts(ctx, InventoryOptions{})
+			return err
+		}, ErrCRDNotInstalled},
+		{"missing cluster", func() error {
+			_, err := session.GenerateKubeconfig(ctx, "c-missing")
+			return err
+		}, ErrClusterNotFound},
+		{"kubeconfig action unavailable", func() error {
+			_, err := session.GenerateKubeconfig(ctx, "c-local")
+			return err
+		}, ErrKubeconfigUnavailable},
+		{"html response", func() error {
+			_, err := session.Clusters().ListClusters(ctx)
+			return err
+		}, ErrBadURL},
+	}
+	for _, tc := range cases {
+		err := tc.call()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%s: expected APIError, got %v", tc.name, err)
+		}
+		if apiErr.Kind != tc.kind {
+			t.Fatalf("%s: expected kind %s, got %s (%v)", tc.name, tc.kind, apiErr.Kind, err)
+		}
+		if apiErr.RequestID != "req-42" || !strings.Contains(err.Error(), "request-id=req-42") {
+			t.Fatalf("%s: expected request ID in %q", tc.name, err.Error())
+		}
+		if !strings.Contains(err.Error(), "endpoint="+server.URL) {
+			t.Fatalf("%s: expected endpoint in %q", tc.name, err.Error())
+		}
+		if apiErr.Hint() == "" {
+			t.Fatalf("%s: expected a hint", tc.name)
+		}
+	}
+}
+
+func TestTransportErrorTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{Retry: RetryPolicy{Attempts: 1}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = session.Clusters().ListClusters(context.Background())
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrTLSFailure {
+		t.Fatalf("expected TLS failure, got %v", err)
+	}
+	if !strings.Contains(apiErr.Hint(), "--rancher-ca-file") {
+		t.Fatalf("unexpected hint %q", apiErr.Hint())
+	}
+}
+
+func TestResolveClusterNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":[{"id":"c-1","name":"prod"}]}`))
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, err = session.Clusters().ResolveCluster(context.Background(), "staging")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Kind != ErrClusterNotFound {
+		t.Fatalf("expected cluster not found, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package rancher
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Impersonation makes Steve requests act as another user. Rancher honours
+// the Kubernetes impersonation headers on the Steve API and the cluster
+// proxy; the v3 endpoints used for cluster discovery, kubeconfig generation
+// and tokens always act as the token owner.
+type Impersonation struct {
+	User   string
+	Groups []string
+	UID    string
+}
+
+func (i Impersonation) Enabled() bool {
+	return i.User != ""
+}
+
+// cacheKey keeps impersonated listings apart from the token owner's.
+func (i Impersonation) cacheKey() []string {
+	if !i.Enabled() {
+		return nil
+	}
+	return append([]string{"as", i.User, i.UID}, i.Groups...)
+}
+
+func (i Impersonation) apply(header http.Header) {
+	if !i.Enabled() {
+		return
+	}
+	header.Set("Impersonate-User", i.User)
+	for _, group := range i.Groups {
+		header.Add("Impersonate-Group", group)
+	}
+	if i.UID != "" {
+		header.Set("Impersonate-Uid", i.UID)
+	}
+}
+
+// impersonationFor returns the impersonation to send to target, or none when
+// target is not served by Steve or the cluster proxy.
+func (s *Session) impersonationFor(target *url.URL) Impersonation {
+	for _, prefix := range [][]string{{"v1"}, {"k8s", "clusters"}} {
+		if strings.HasPrefix(target.Path, s.baseURL.JoinPath(prefix...).Path+"/") {
+			return s.impersonate
+		}
+	}
+	return Impersonation{}
+}
@@ -0,0 +1,77 @@
+package rancher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestImpersonationOnlyOnSteve(t *testing.T) {
+	seen := map[string]http.Header{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen[r.URL.Path] = r.Header.Clone()
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	as := Impersonation{User: "alice", Groups: []string{"ops", "support"}, UID: "u-1"}
+	session, err := NewSession(server.URL+"/rancher", "token", SessionOptions{Impersonate: as})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := session.Inventories().ListInventoryHosts(ctx, InventoryOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := session.Clusters().ListClusters(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	steve := seen["/rancher"+DefaultInventoryPath]
+	if got := steve.Get("Impersonate-User"); got != "alice" {
+		t.Fatalf("expected Impersonate-User alice on Steve, got %q", got)
+	}
+	if got := steve.Values("Impersonate-Group"); !slices.Equal(got, as.Groups) {
+		t.Fatalf("unexpected Impersonate-Group %v", got)
+	}
+	if got := steve.Get("Impersonate-Uid"); got != "u-1" {
+		t.Fatalf("unexpected Impersonate-Uid %q", got)
+	}
+	if got := seen["/rancher"+DefaultClustersPath].Get("Impersonate-User"); got != "" {
+		t.Fatalf("expected no impersonation on v3, got %q", got)
+	}
+
+	plain, err := NewSession(server.URL+"/rancher", "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if plain.ResultCacheName("inventories") == session.ResultCacheName("inventories") {
+		t.Fatalf("expected impersonated results to be cached separately")
+	}
+}
+
+func TestWithTokenKeepsImpersonation(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{Impersonate: Impersonation{User: "alice"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	derived := session.WithToken("other")
+	if _, err := derived.Inventories().ListInventoryHosts(context.Background(), InventoryOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := header.Get("Authorization"); got != "Bearer other" {
+		t.Fatalf("expected the derived token, got %q", got)
+	}
+	if got := header.Get("Impersonate-User"); got != "alice" {
+		t.Fatalf("expected the derived session to keep impersonating, got %q", got)
+	}
+}
@@ -0,0 +1,69 @@
+package rancher
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// InventoryOptions narrows the MachineInventory listing. Both fields are sent
+// to the Steve API, as the namespace path segment and the labelSelector query
+// parameter (Steve's filter parameter is not used), and applied again
+// client-side, so servers that ignore them still yield the expected hosts.
+type InventoryOptions struct {
+	Namespace string
+	Selector  labels.Selector
+}
+
+func (o InventoryOptions) hasSelector() bool {
+	return o.Selector != nil && !o.Selector.Empty()
+}
+
+func inventoryListURL(base *url.URL, opts InventoryOptions, withSelector bool) *url.URL {
+	clone := *base
+	if opts.Namespace != "" {
+		clone.Path = strings.TrimSuffix(clone.Path, "/") + "/" + url.PathEscape(opts.Namespace)
+		clone.RawPath = ""
+	}
+	q := clone.Query()
+	if withSelector && opts.hasSelector() {
+		q.Set("labelSelector", opts.Selector.String())
+	} else {
+		q.Del("labelSelector")
+	}
+	clone.RawQuery = q.Encode()
+	return &clone
+}
+
+func filterInventoryHosts(hosts []types.InventoryHost, opts InventoryOptions) []types.InventoryHost {
+	if opts.Namespace == "" && !opts.hasSelector() {
+		return hosts
+	}
+	filtered := make([]types.InventoryHost, 0, len(hosts))
+	for _, host := range hosts {
+		if opts.Matches(host) {
+			filtered = append(filtered, host)
+		}
+	}
+	return filtered
+}
+
+// Matches reports whether host is in the namespace and matches the selector.
+func (o InventoryOptions) Matches(host types.InventoryHost) bool {
+	if o.Namespace != "" && host.Namespace != "" && host.Namespace != o.Namespace {
+		return false
+	}
+	return !o.hasSelector() || o.Selector.Matches(labels.Set(host.Labels))
+}
+
+func isUnsupportedQuery(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity
+}
@@ -0,0 +1,77 @@
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func TestInventoryListURL(t *testing.T) {
+	base, _ := url.Parse("https://rancher.example.com" + DefaultInventoryPath)
+	selector, err := labels.Parse("env=prod")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := inventoryListURL(base, InventoryOptions{Namespace: "fleet-default", Selector: selector}, true)
+	expected := "https://rancher.example.com" + DefaultInventoryPath + "/fleet-default?labelSelector=env%3Dprod"
+	if got.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, got.String())
+	}
+	got = inventoryListURL(base, InventoryOptions{Selector: selector}, false)
+	if got.RawQuery != "" {
+		t.Fatalf("expected selector to be dropped, got %s", got.RawQuery)
+	}
+}
+
+func TestFilterInventoryHosts(t *testing.T) {
+	selector, _ := labels.Parse("env=prod")
+	hosts := []types.InventoryHost{
+		{ID: "a", Namespace: "fleet-default", Labels: map[string]string{"env": "prod"}},
+		{ID: "b", Namespace: "fleet-default", Labels: map[string]string{"env": "dev"}},
+		{ID: "c", Namespace: "other", Labels: map[string]string{"env": "prod"}},
+	}
+	filtered := filterInventoryHosts(hosts, InventoryOptions{Namespace: "fleet-default", Selector: selector})
+	if len(filtered) != 1 || filtered[0].ID != "a" {
+		t.Fatalf("expected only host a, got %+v", filtered)
+	}
+}
+
+func TestListInventoryHostsSelectorFallback(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get("labelSelector"))
+		if r.URL.Query().Get("labelSelector") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": []map[string]any{
+				{"id": "fleet-default/a", "metadata": map[string]any{"name": "a", "labels": map[string]any{"env": "prod"}}},
+				{"id": "fleet-default/b", "metadata": map[string]any{"name": "b", "labels": map[string]any{"env": "dev"}}},
+			},
+		})
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	selector, _ := labels.Parse("env=prod")
+	hosts, err := session.Inventories().ListInventoryHosts(context.Background(), InventoryOptions{Selector: selector})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].ID != "fleet-default/a" {
+		t.Fatalf("expected client-side filtered host, got %+v", hosts)
+	}
+	if len(queries) != 2 || queries[0] != "env=prod" || queries[1] != "" {
+		t.Fatalf("expected server-side attempt then fallback, got %v", queries)
+	}
+}
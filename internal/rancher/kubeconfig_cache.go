@@ -1,60 +1,153 @@
 package rancher
 
 import (
-	"crypto/sha256"
-	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 	"time"
 )
 
 const DefaultKubeconfigCacheTTL = 10 * time.Minute
 
-func KubeconfigCacheKey(baseURL string, clusterID string) string {
-	return baseURL + "|" + clusterID
+const kubeconfigCachePrefix = "kubeconfig-"
+
+// KubeconfigCacheEntry is a downstream kubeconfig generated by Rancher
+// together with what is needed to judge whether it is still usable.
+type KubeconfigCacheEntry struct {
+	RancherURL     string    `json:"rancherURL"`
+	ClusterID      string    `json:"clusterID"`
+	ClusterName    string    `json:"clusterName,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	TokenExpiresAt time.Time `json:"tokenExpiresAt,omitzero"`
+	Kubeconfig     string    `json:"kubeconfig"`
+	// Name is the cache entry the kubeconfig was read from.
+	Name string `json:"-"`
+	// Err is set on entries ListKubeconfigs could not read or decrypt.
+	Err error `json:"-"`
+}
+
+func (e KubeconfigCacheEntry) Age(now time.Time) time.Duration {
+	return now.Sub(e.CreatedAt)
+}
+
+// Expired reports whether the entry is older than ttl (ttl <= 0 never ages
+// out) or its embedded token has expired.
+func (e KubeconfigCacheEntry) Expired(ttl time.Duration, now time.Time) bool {
+	if ttl > 0 && e.Age(now) > ttl {
+		return true
+	}
+	return !e.TokenExpiresAt.IsZero() && !now.Before(e.TokenExpiresAt)
 }
 
-func LoadCachedKubeconfig(key string, ttl time.Duration) ([]byte, time.Duration, bool, error) {
-	path, err := kubeconfigCachePath(key)
+func (c *Cache) LoadKubeconfig(rancherURL, clusterID string, ttl time.Duration) (KubeconfigCacheEntry, bool, error) {
+	name, err := kubeconfigCacheName(rancherURL, clusterID)
 	if err != nil {
-		return nil, 0, false, err
+		return KubeconfigCacheEntry{}, false, err
+	}
+	entry, ok, err := c.readKubeconfig(name)
+	if err != nil || !ok {
+		return KubeconfigCacheEntry{}, false, err
+	}
+	if entry.Expired(ttl, time.Now()) {
+		return entry, false, nil
 	}
-	info, err := os.Stat(path)
+	return entry, true, nil
+}
+
+func (c *Cache) SaveKubeconfig(entry KubeconfigCacheEntry) error {
+	name, err := kubeconfigCacheName(entry.RancherURL, entry.ClusterID)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, 0, false, nil
-		}
-		return nil, 0, false, err
+		return err
 	}
-	age := time.Since(info.ModTime())
-	if ttl > 0 && age > ttl {
-		return nil, age, false, nil
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
 	}
-	data, err := os.ReadFile(path)
+	data, err := json.Marshal(entry)
 	if err != nil {
-		return nil, 0, false, err
+		return err
 	}
-	return data, age, true, nil
+	return c.Put(name, data)
 }
 
-func SaveCachedKubeconfig(key string, data []byte) error {
-	path, err := kubeconfigCachePath(key)
+func (c *Cache) DeleteKubeconfig(rancherURL, clusterID string) error {
+	name, err := kubeconfigCacheName(rancherURL, clusterID)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-		return err
+	return c.Delete(name)
+}
+
+// ListKubeconfigs returns every entry, oldest first. Entries that cannot be
+// parsed or decrypted come last, with only Name and Err set.
+func (c *Cache) ListKubeconfigs() ([]KubeconfigCacheEntry, error) {
+	names, err := c.List(kubeconfigCachePrefix)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]KubeconfigCacheEntry, 0, len(names))
+	for _, name := range names {
+		entry, ok, err := c.readKubeconfig(name)
+		switch {
+		case err != nil:
+			entries = append(entries, KubeconfigCacheEntry{Name: name, Err: err})
+		case ok:
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if (entries[i].Err == nil) != (entries[j].Err == nil) {
+			return entries[i].Err == nil
+		}
+		return entries[i].CreatedAt.Before(entries[j].CreatedAt)
+	})
+	return entries, nil
+}
+
+// PurgeKubeconfigs removes the entries for which remove returns true; a nil
+// filter removes everything, including unreadable and legacy files.
+func (c *Cache) PurgeKubeconfigs(remove func(KubeconfigCacheEntry) bool) (int, error) {
+	names, err := c.List(kubeconfigCachePrefix)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	var errs []error
+	for _, name := range names {
+		if remove != nil {
+			entry, ok, err := c.readKubeconfig(name)
+			if err != nil || !ok || !remove(entry) {
+				continue
+			}
+		}
+		if err := c.Delete(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
+
+func (c *Cache) readKubeconfig(name string) (KubeconfigCacheEntry, bool, error) {
+	data, ok, err := c.Get(name)
+	if err != nil || !ok {
+		return KubeconfigCacheEntry{}, false, err
+	}
+	var entry KubeconfigCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return KubeconfigCacheEntry{}, false, fmt.Errorf("invalid kubeconfig cache entry %s: %w", name, err)
+	}
+	if entry.Kubeconfig == "" {
+		return KubeconfigCacheEntry{}, false, fmt.Errorf("kubeconfig cache entry %s is empty", name)
 	}
-	return os.WriteFile(path, data, 0600)
+	entry.Name = name
+	return entry, true, nil
 }
 
-func kubeconfigCachePath(key string) (string, error) {
-	base, err := os.UserCacheDir()
-	if err != nil || base == "" {
-		base = os.TempDir()
+func kubeconfigCacheName(rancherURL, clusterID string) (string, error) {
+	if rancherURL == "" || clusterID == "" {
+		return "", fmt.Errorf("rancher URL and cluster ID are required for the kubeconfig cache")
 	}
-	sum := sha256.Sum256([]byte(key))
-	name := fmt.Sprintf("kubeconfig-%s.yaml", hex.EncodeToString(sum[:16]))
-	return filepath.Join(base, "elemental-node-map", name), nil
+	return CacheName(kubeconfigCachePrefix, ".json", rancherURL, clusterID), nil
 }
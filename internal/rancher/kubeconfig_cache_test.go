@@ -0,0 +1,99 @@
+package rancher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestKubeconfigCacheRoundTrip(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entry := KubeconfigCacheEntry{
+		RancherURL:  "https://rancher.example.com",
+		ClusterID:   "c-1",
+		ClusterName: "prod",
+		Kubeconfig:  "apiVersion: v1\nkind: Config\n",
+	}
+	if err := cache.SaveKubeconfig(entry); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded, hit, err := cache.LoadKubeconfig(entry.RancherURL, entry.ClusterID, time.Minute)
+	if err != nil || !hit {
+		t.Fatalf("expected cache hit, hit=%v err=%v", hit, err)
+	}
+	if loaded.Kubeconfig != entry.Kubeconfig || loaded.ClusterName != "prod" || loaded.CreatedAt.IsZero() {
+		t.Fatalf("unexpected entry %+v", loaded)
+	}
+	info, err := os.Stat(filepath.Join(cache.Dir(), loaded.Name))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("expected 0600 cache file, err=%v", err)
+	}
+
+	if _, hit, _ := cache.LoadKubeconfig(entry.RancherURL, "c-2", time.Minute); hit {
+		t.Fatalf("expected miss for another cluster")
+	}
+	if err := cache.DeleteKubeconfig(entry.RancherURL, entry.ClusterID); err != nil {
+		t.Fatalf("unexpected delete error: %v", err)
+	}
+	if _, hit, _ := cache.LoadKubeconfig(entry.RancherURL, entry.ClusterID, time.Minute); hit {
+		t.Fatalf("expected miss after delete")
+	}
+}
+
+func TestKubeconfigCacheExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	entry := KubeconfigCacheEntry{CreatedAt: now.Add(-5 * time.Minute)}
+	if entry.Expired(10*time.Minute, now) {
+		t.Fatalf("entry within TTL should be valid")
+	}
+	if !entry.Expired(time.Minute, now) {
+		t.Fatalf("entry past TTL should be expired")
+	}
+	if entry.Expired(0, now) {
+		t.Fatalf("zero TTL should never age out")
+	}
+	entry.TokenExpiresAt = now.Add(-time.Second)
+	if !entry.Expired(0, now) {
+		t.Fatalf("entry with expired token should be expired")
+	}
+}
+
+func TestPurgeKubeconfigs(t *testing.T) {
+	cache, err := NewCache(t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, id := range []string{"c-1", "c-2"} {
+		if err := cache.SaveKubeconfig(KubeconfigCacheEntry{RancherURL: "https://rancher.example.com", ClusterID: id, Kubeconfig: "config"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	legacy := filepath.Join(cache.Dir(), "kubeconfig-legacy.yaml")
+	if err := os.WriteFile(legacy, []byte("config"), 0600); err != nil {
+		t.Fatalf("write legacy: %v", err)
+	}
+
+	entries, err := cache.ListKubeconfigs()
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d err=%v", len(entries), err)
+	}
+	if entries[0].Err != nil || entries[1].Err != nil || entries[2].Err == nil || entries[2].Name != "kubeconfig-legacy.yaml" {
+		t.Fatalf("expected the legacy file listed last as unreadable, got %+v", entries)
+	}
+
+	removed, err := cache.PurgeKubeconfigs(func(entry KubeconfigCacheEntry) bool { return entry.ClusterID == "c-1" })
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one entry removed, got %d err=%v", removed, err)
+	}
+	removed, err = cache.PurgeKubeconfigs(nil)
+	if err != nil || removed != 2 {
+		t.Fatalf("expected remaining entry and legacy file removed, got %d err=%v", removed, err)
+	}
+}
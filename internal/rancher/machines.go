@@ -1,11 +1,6 @@
 package rancher
 
-import (
-	"context"
-	"fmt"
-	"net/url"
-	"strings"
-)
+import "context"
 
 type Machine struct {
 	ID          string
@@ -17,38 +12,26 @@ type Machine struct {
 	Annotations map[string]string
 }
 
-func MachinesURLFromInventoryURL(raw string) (*url.URL, error) {
-	parsed, err := url.Parse(raw)
+func (c *Client) ListMachines(ctx context.Context) ([]Machine, error) {
+	var machines []Machine
+	err := c.StreamMachines(ctx, func(page []Machine) error {
+		machines = append(machines, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("invalid rancher URL: %w", err)
-	}
-	parsed.RawQuery = ""
-	parsed.Fragment = ""
-	basePath := strings.TrimSuffix(stripAPISuffix(parsed.Path), "/")
-	if basePath == "" {
-		parsed.Path = "/v1/cluster.x-k8s.io.machine"
-	} else {
-		parsed.Path = basePath + "/v1/cluster.x-k8s.io.machine"
+		return nil, err
 	}
-	return parsed, nil
+	return machines, nil
 }
 
-func (c *Client) ListMachines(ctx context.Context) ([]Machine, error) {
-	var machines []Machine
-	nextURL := c.withLimit(c.baseURL, 200)
-
-	for nextURL != nil {
-		page, err := c.fetchPage(ctx, nextURL)
-		if err != nil {
-			return nil, err
-		}
+func (c *Client) StreamMachines(ctx context.Context, fn func([]Machine) error) error {
+	return c.walkPages(ctx, c.baseURL, func(page listResponse) error {
+		machines := make([]Machine, 0, len(page.Data))
 		for _, raw := range page.Data {
 			machines = append(machines, normalizeMachine(raw))
 		}
-		nextURL = page.NextURL(c.baseURL)
-	}
-
-	return machines, nil
+		return fn(machines)
+	})
 }
 
 func MachineNameMap(machines []Machine, clusterName string) map[string]string {
@@ -2,49 +2,37 @@ package rancher
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"net/url"
 	"strings"
-	"time"
 )
 
 type Cluster struct {
-	ID   string
-	Name string
+	ID     string
+	Name   string
+	Labels map[string]string
 }
 
-func ClustersURLFromInventoryURL(raw string) (*url.URL, error) {
-	parsed, err := url.Parse(raw)
+func (c *Client) ListClusters(ctx context.Context) ([]Cluster, error) {
+	var clusters []Cluster
+	err := c.StreamClusters(ctx, func(page []Cluster) error {
+		clusters = append(clusters, page...)
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("invalid rancher URL: %w", err)
-	}
-	parsed.RawQuery = ""
-	parsed.Fragment = ""
-	basePath := strings.TrimSuffix(stripAPISuffix(parsed.Path), "/")
-	if basePath == "" {
-		parsed.Path = "/v3/clusters"
-	} else {
-		parsed.Path = basePath + "/v3/clusters"
+		return nil, err
 	}
-	return parsed, nil
+	return clusters, nil
 }
 
-func (c *Client) ListClusters(ctx context.Context) ([]Cluster, error) {
-	var clusters []Cluster
-	nextURL := c.withLimit(c.baseURL, 200)
-
-	for nextURL != nil {
-		page, err := c.fetchPage(ctx, nextURL)
-		if err != nil {
-			return nil, err
-		}
+func (c *Client) StreamClusters(ctx context.Context, fn func([]Cluster) error) error {
+	return c.walkPages(ctx, c.baseURL, func(page listResponse) error {
+		clusters := make([]Cluster, 0, len(page.Data))
 		for _, raw := range page.Data {
 			clusters = append(clusters, normalizeCluster(raw))
 		}
-		nextURL = page.NextURL(c.baseURL)
-	}
-
-	return clusters, nil
+		return fn(clusters)
+	})
 }
 
 func (c *Client) ResolveCluster(ctx context.Context, identifier string) (Cluster, error) {
@@ -55,7 +43,19 @@ func (c *Client) ResolveCluster(ctx context.Context, identifier string) (Cluster
 	if err != nil {
 		return Cluster{}, err
 	}
+	cluster, err := FindCluster(clusters, identifier)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		apiErr.Endpoint = c.baseURL.String()
+	}
+	return cluster, err
+}
 
+// FindCluster picks the cluster whose ID, or else unique name, is identifier.
+func FindCluster(clusters []Cluster, identifier string) (Cluster, error) {
+	if identifier == "" {
+		return Cluster{}, fmt.Errorf("rancher cluster is required")
+	}
 	var nameMatches []Cluster
 	for _, cluster := range clusters {
 		if cluster.ID == identifier {
@@ -70,7 +70,11 @@ func (c *Client) ResolveCluster(ctx context.Context, identifier string) (Cluster
 	case 1:
 		return nameMatches[0], nil
 	case 0:
-		return Cluster{}, fmt.Errorf("cluster %q not found", identifier)
+		return Cluster{}, &APIError{
+			Kind:    ErrClusterNotFound,
+			Message: fmt.Sprintf("no cluster with ID or name %q", identifier),
+			Err:     fmt.Errorf("cluster %q not found", identifier),
+		}
 	default:
 		return Cluster{}, fmt.Errorf("multiple clusters named %q: %s", identifier, joinClusterIDs(nameMatches))
 	}
@@ -90,23 +94,15 @@ func (c *Client) GenerateKubeconfig(ctx context.Context, clusterID string) ([]by
 	q.Set("action", "generateKubeconfig")
 	target.RawQuery = q.Encode()
 
+	// Every successful call mints a kubeconfig token, so only retry when
+	// the request cannot have been acted on.
 	var payload map[string]any
-	var lastErr error
-	for attempt := 0; attempt < defaultRetries; attempt++ {
+	err := c.withUnsafeRetry(ctx, target.String(), func() error {
 		payload = nil
-		if err := c.doJSONRequest(ctx, "POST", &target, &payload); err != nil {
-			lastErr = err
-			if shouldRetry(err) {
-				time.Sleep(backoff(attempt))
-				continue
-			}
-			return nil, err
-		}
-		lastErr = nil
-		break
-	}
-	if lastErr != nil {
-		return nil, lastErr
+		return c.doJSONRequest(ctx, "POST", &target, nil, &payload)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	config := firstString(payload, "config", "kubeconfig")
@@ -118,8 +114,9 @@ func (c *Client) GenerateKubeconfig(ctx context.Context, clusterID string) ([]by
 
 func normalizeCluster(raw map[string]any) Cluster {
 	return Cluster{
-		ID:   firstString(raw, "id"),
-		Name: firstString(raw, "name"),
+		ID:     firstString(raw, "id"),
+		Name:   firstString(raw, "name"),
+		Labels: firstStringMap(raw, "labels", "metadata.labels"),
 	}
 }
 
@@ -0,0 +1,55 @@
+package rancher
+
+import (
+	"context"
+	"net/url"
+)
+
+const DefaultPageSize = 200
+
+type pageResult struct {
+	page listResponse
+	err  error
+}
+
+// walkPages follows the collection's next links in a background goroutine so
+// the following page is already in flight while fn handles the current one.
+func (c *Client) walkPages(ctx context.Context, start *url.URL, fn func(listResponse) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make(chan pageResult, 1)
+	go func() {
+		defer close(pages)
+		nextURL := c.withLimit(start, c.limit())
+		for nextURL != nil {
+			page, err := c.fetchPage(ctx, nextURL)
+			select {
+			case pages <- pageResult{page: page, err: err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+			nextURL = page.NextURL(start)
+		}
+	}()
+
+	for result := range pages {
+		if result.err != nil {
+			return result.err
+		}
+		if err := fn(result.page); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+func (c *Client) limit() int {
+	if c.pageSize > 0 {
+		return c.pageSize
+	}
+	return DefaultPageSize
+}
@@ -0,0 +1,83 @@
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+)
+
+func pagedServer(t *testing.T, pages int, limits *[]string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*limits = append(*limits, r.URL.Query().Get("limit"))
+		page, _ := strconv.Atoi(r.URL.Query().Get("continue"))
+		payload := map[string]any{
+			"data": []map[string]any{{"id": fmt.Sprintf("fleet-default/host-%d", page)}},
+		}
+		if page+1 < pages {
+			payload["pagination"] = map[string]any{"next": fmt.Sprintf("?limit=%s&continue=%d", r.URL.Query().Get("limit"), page+1)}
+		}
+		_ = json.NewEncoder(w).Encode(payload)
+	}))
+}
+
+func TestStreamInventoryHostsPages(t *testing.T) {
+	var limits []string
+	server := pagedServer(t, 3, &limits)
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{PageSize: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var batches [][]types.InventoryHost
+	err = session.Inventories().StreamInventoryHosts(context.Background(), InventoryOptions{}, func(hosts []types.InventoryHost) error {
+		batches = append(batches, hosts)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(batches) != 3 {
+		t.Fatalf("expected 3 pages, got %d", len(batches))
+	}
+	for i, batch := range batches {
+		if len(batch) != 1 || batch[0].ID != fmt.Sprintf("fleet-default/host-%d", i) {
+			t.Fatalf("unexpected page %d: %+v", i, batch)
+		}
+	}
+	for _, limit := range limits {
+		if limit != "50" {
+			t.Fatalf("expected page size 50, got %s", limit)
+		}
+	}
+}
+
+func TestStreamInventoryHostsStopsOnCallbackError(t *testing.T) {
+	var limits []string
+	server := pagedServer(t, 10, &limits)
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stop := errors.New("stop")
+	err = session.Inventories().StreamInventoryHosts(context.Background(), InventoryOptions{}, func([]types.InventoryHost) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("expected callback error, got %v", err)
+	}
+	server.Close()
+	if len(limits) > 3 {
+		t.Fatalf("expected prefetching to stop early, got %d requests", len(limits))
+	}
+}
@@ -0,0 +1,73 @@
+package rancher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	InventorySchemaID = "elemental.cattle.io.machineinventory"
+	MachineSchemaID   = "cluster.x-k8s.io.machine"
+)
+
+// CurrentUser returns the username the session token belongs to.
+func (s *Session) CurrentUser(ctx context.Context) (string, error) {
+	target := s.baseURL.JoinPath("/v3/users")
+	q := target.Query()
+	q.Set("me", "true")
+	target.RawQuery = q.Encode()
+	client := s.client(target)
+	var payload listResponse
+	err := client.withRetry(ctx, target.String(), func() error {
+		var err error
+		payload, err = client.doRequest(ctx, target)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(payload.Data) == 0 {
+		return "", &APIError{Kind: ErrAuthFailed, Endpoint: target.String(), Message: "token is not bound to a user"}
+	}
+	return firstString(payload.Data[0], "username", "name", "id"), nil
+}
+
+// HasSchema reports whether Steve serves the schema with the given ID, which
+// requires the CRD to be installed and visible to the token.
+func (s *Session) HasSchema(ctx context.Context, id string) (bool, error) {
+	target := s.baseURL.JoinPath("/v1/schemas", id)
+	client := s.client(target)
+	err := client.withRetry(ctx, target.String(), func() error {
+		return client.doJSONRequest(ctx, http.MethodGet, target, nil, nil)
+	})
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// CanGenerateKubeconfig reports whether the token may invoke the
+// generateKubeconfig action on the cluster; Rancher only lists the actions
+// the caller is allowed to perform.
+func (s *Session) CanGenerateKubeconfig(ctx context.Context, clusterID string) (bool, error) {
+	if clusterID == "" {
+		return false, fmt.Errorf("rancher cluster is required")
+	}
+	target := s.baseURL.JoinPath(DefaultClustersPath, clusterID)
+	client := s.client(target)
+	var payload struct {
+		Actions map[string]string `json:"actions"`
+	}
+	err := client.withRetry(ctx, target.String(), func() error {
+		payload.Actions = nil
+		return client.doJSONRequest(ctx, http.MethodGet, target, nil, &payload)
+	})
+	if err != nil {
+		return false, err
+	}
+	_, ok := payload.Actions["generateKubeconfig"]
+	return ok, nil
+}
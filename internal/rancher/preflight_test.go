@@ -0,0 +1,58 @@
+package rancher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPreflightChecks(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v3/users", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("me") != "true" {
+			t.Errorf("expected me=true, got %q", r.URL.RawQuery)
+		}
+		w.Write([]byte(`{"data":[{"id":"u-abc","username":"admin"}]}`))
+	})
+	mux.HandleFunc("/v1/schemas/"+InventorySchemaID, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"` + InventorySchemaID + `"}`))
+	})
+	mux.HandleFunc("/v3/clusters/c-allowed", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"c-allowed","actions":{"generateKubeconfig":"https://rancher/v3/clusters/c-allowed?action=generateKubeconfig"}}`))
+	})
+	mux.HandleFunc("/v3/clusters/c-denied", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"c-denied","actions":{}}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	user, err := session.CurrentUser(ctx)
+	if err != nil || user != "admin" {
+		t.Fatalf("expected admin, got %q (%v)", user, err)
+	}
+
+	found, err := session.HasSchema(ctx, InventorySchemaID)
+	if err != nil || !found {
+		t.Fatalf("expected inventory schema, got %v (%v)", found, err)
+	}
+	found, err = session.HasSchema(ctx, MachineSchemaID)
+	if err != nil || found {
+		t.Fatalf("expected missing machine schema without error, got %v (%v)", found, err)
+	}
+
+	allowed, err := session.CanGenerateKubeconfig(ctx, "c-allowed")
+	if err != nil || !allowed {
+		t.Fatalf("expected generateKubeconfig to be allowed, got %v (%v)", allowed, err)
+	}
+	allowed, err = session.CanGenerateKubeconfig(ctx, "c-denied")
+	if err != nil || allowed {
+		t.Fatalf("expected generateKubeconfig to be denied, got %v (%v)", allowed, err)
+	}
+}
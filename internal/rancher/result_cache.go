@@ -0,0 +1,72 @@
+package rancher
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DefaultResultCacheTTL keeps list results long enough for interactive
+// exploration without hiding real changes for long.
+const DefaultResultCacheTTL = 2 * time.Minute
+
+const resultCachePrefix = "result-"
+
+type cachedResult struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// ResultCacheName derives the entry name for a list result from everything
+// that affects it: endpoint, cluster, selectors and the credential used.
+func ResultCacheName(parts ...string) string {
+	return CacheName(resultCachePrefix, ".json", parts...)
+}
+
+// LoadResult decodes a cached result into out when it is younger than ttl.
+func (c *Cache) LoadResult(name string, ttl time.Duration, out any) (time.Duration, bool, error) {
+	data, ok, err := c.Get(name)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	var entry cachedResult
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return 0, false, fmt.Errorf("invalid result cache entry %s: %w", name, err)
+	}
+	age := time.Since(entry.CreatedAt)
+	if ttl <= 0 || age > ttl {
+		return age, false, nil
+	}
+	if err := json.Unmarshal(entry.Data, out); err != nil {
+		return 0, false, fmt.Errorf("invalid result cache entry %s: %w", name, err)
+	}
+	return age, true, nil
+}
+
+func (c *Cache) SaveResult(name string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	entry, err := json.Marshal(cachedResult{CreatedAt: time.Now().UTC(), Data: data})
+	if err != nil {
+		return err
+	}
+	return c.Put(name, entry)
+}
+
+// PurgeResults removes every cached list result.
+func (c *Cache) PurgeResults() (int, error) {
+	names, err := c.List(resultCachePrefix)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, name := range names {
+		if err := c.Delete(name); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
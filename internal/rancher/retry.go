@@ -0,0 +1,165 @@
+package rancher
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RetryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:  3,
+	BaseDelay: 250 * time.Millisecond,
+	MaxDelay:  30 * time.Second,
+}
+
+// RetryEvent describes a failed attempt that is about to be retried.
+type RetryEvent struct {
+	Endpoint string
+	Attempt  int
+	Attempts int
+	Wait     time.Duration
+	Err      error
+}
+
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.Attempts <= 0 {
+		p.Attempts = DefaultRetryPolicy.Attempts
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = DefaultRetryPolicy.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultRetryPolicy.MaxDelay
+	}
+	return p
+}
+
+// delay returns the wait before the next attempt. Retry-After wins when the
+// server sends one, capped at MaxDelay so a long one still gets retried;
+// otherwise exponential backoff with jitter in [d/2, d].
+func (p RetryPolicy) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		return min(apiErr.RetryAfter, p.MaxDelay)
+	}
+	d := p.BaseDelay << attempt
+	if d <= 0 || d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// withRetry retries fn on the errors an idempotent request may be repeated
+// after.
+func (c *Client) withRetry(ctx context.Context, endpoint string, fn func() error) error {
+	return c.retryWhile(ctx, endpoint, shouldRetry, fn)
+}
+
+// withUnsafeRetry retries a request that is not idempotent, such as a POST
+// action, only when repeating it cannot make the server act twice.
+func (c *Client) withUnsafeRetry(ctx context.Context, endpoint string, fn func() error) error {
+	return c.retryWhile(ctx, endpoint, shouldRetryUnsafe, fn)
+}
+
+func (c *Client) retryWhile(ctx context.Context, endpoint string, retryable func(context.Context, error) bool, fn func() error) error {
+	policy := c.retry.normalized()
+	var err error
+	for attempt := 0; attempt < policy.Attempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if attempt == policy.Attempts-1 || !retryable(ctx, err) {
+			return err
+		}
+		wait := policy.delay(attempt, err)
+		if c.retries != nil {
+			c.retries.Add(1)
+		}
+		if c.onRetry != nil {
+			c.onRetry(RetryEvent{Endpoint: endpoint, Attempt: attempt + 1, Attempts: policy.Attempts, Wait: wait, Err: err})
+		}
+		if err := sleepContext(ctx, wait); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		if apiErr.StatusCode == 0 {
+			return apiErr.Kind == ErrUnreachable
+		}
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// shouldRetryUnsafe allows a retry when the request provably never reached
+// the server (the connection or DNS lookup failed), or when the server
+// rejected it with 429 or 503 and said when to come back.
+func shouldRetryUnsafe(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode != 0 {
+		return (apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode == http.StatusServiceUnavailable) && apiErr.RetryAfter > 0
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		if wait := when.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
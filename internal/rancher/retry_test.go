@@ -0,0 +1,161 @@
+package rancher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter("5", now); got != 5*time.Second {
+		t.Fatalf("expected 5s, got %s", got)
+	}
+	date := now.Add(3 * time.Second).Format(http.TimeFormat)
+	if got := parseRetryAfter(date, now); got != 3*time.Second {
+		t.Fatalf("expected 3s, got %s", got)
+	}
+	if got := parseRetryAfter("garbage", now); got != 0 {
+		t.Fatalf("expected 0 for invalid header, got %s", got)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Attempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt := 0; attempt < 6; attempt++ {
+		wait := policy.delay(attempt, errors.New("boom"))
+		ceiling := policy.BaseDelay << attempt
+		if ceiling > policy.MaxDelay {
+			ceiling = policy.MaxDelay
+		}
+		if wait < ceiling/2 || wait > ceiling {
+			t.Fatalf("attempt %d: wait %s outside [%s, %s]", attempt, wait, ceiling/2, ceiling)
+		}
+	}
+	if wait := policy.delay(0, &APIError{StatusCode: 429, RetryAfter: 500 * time.Millisecond}); wait != 500*time.Millisecond {
+		t.Fatalf("expected Retry-After to be honoured, got %s", wait)
+	}
+	if wait := policy.delay(0, &APIError{StatusCode: 429, RetryAfter: time.Minute}); wait != policy.MaxDelay {
+		t.Fatalf("expected Retry-After beyond max delay to be capped at %s, got %s", policy.MaxDelay, wait)
+	}
+}
+
+func TestFetchRetriesTooManyRequests(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"id": "c-1", "name": "one"}}})
+	}))
+	defer server.Close()
+
+	var events []RetryEvent
+	session, err := NewSession(server.URL, "token", SessionOptions{
+		Retry:   RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond},
+		OnRetry: func(event RetryEvent) { events = append(events, event) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clusters, err := session.Clusters().ListClusters(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(clusters) != 1 || calls != 2 {
+		t.Fatalf("expected one retry then success, got %d clusters after %d calls", len(clusters), calls)
+	}
+	if len(events) != 1 || session.RetryCount() != 1 {
+		t.Fatalf("expected 1 retry event, got %d (count %d)", len(events), session.RetryCount())
+	}
+}
+
+func TestRetryStopsOnContextCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{
+		Retry: RetryPolicy{Attempts: 10, BaseDelay: 10 * time.Second, MaxDelay: 10 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = session.Clusters().ListClusters(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("retry wait ignored context cancellation")
+	}
+}
+
+func TestGenerateKubeconfigRetriesOnlyWhenSafe(t *testing.T) {
+	cases := []struct {
+		name       string
+		status     int
+		retryAfter string
+		wantCalls  int
+	}{
+		{"server error is not repeated", http.StatusInternalServerError, "", 1},
+		{"unavailable without Retry-After is not repeated", http.StatusServiceUnavailable, "", 1},
+		{"too many requests with Retry-After is repeated", http.StatusTooManyRequests, "1", 2},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				if calls == 1 {
+					if tc.retryAfter != "" {
+						w.Header().Set("Retry-After", tc.retryAfter)
+					}
+					w.WriteHeader(tc.status)
+					return
+				}
+				_ = json.NewEncoder(w).Encode(map[string]any{"config": "apiVersion: v1"})
+			}))
+			defer server.Close()
+
+			session, err := NewSession(server.URL, "token", SessionOptions{
+				Retry: RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			_, _ = session.GenerateKubeconfig(context.Background(), "c-1")
+			if calls != tc.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tc.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestGenerateKubeconfigRetriesRefusedConnections(t *testing.T) {
+	server := httptest.NewServer(nil)
+	server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{
+		Retry: RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := session.GenerateKubeconfig(context.Background(), "c-1"); err == nil {
+		t.Fatalf("expected an error from a closed server")
+	}
+	if session.RetryCount() != 2 {
+		t.Fatalf("expected refused connections to be retried twice, got %d", session.RetryCount())
+	}
+}
@@ -0,0 +1,210 @@
+package rancher
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultMachinesPath = "/v1/cluster.x-k8s.io.machine"
+	DefaultClustersPath = "/v3/clusters"
+)
+
+type SessionOptions struct {
+	InsecureSkipVerify bool
+	// CAFile and CAData add trusted roots on top of the system pool.
+	CAFile string
+	CAData []byte
+	// CertFile and KeyFile enable mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ProxyURL overrides HTTPS_PROXY/NO_PROXY from the environment.
+	ProxyURL string
+	// Timeout bounds each HTTP request; zero uses the default.
+	Timeout time.Duration
+	Retry   RetryPolicy
+	OnRetry func(RetryEvent)
+	// PageSize is the limit requested per list page; zero uses DefaultPageSize.
+	PageSize int
+	// Impersonate is sent on Steve requests; see Impersonation.
+	Impersonate Impersonation
+}
+
+// Session is a connection to one Rancher server. All accessors share the
+// same transport, token and TLS settings.
+type Session struct {
+	baseURL      *url.URL
+	inventoryURL *url.URL
+	token        string
+	httpClient   *http.Client
+	retry        RetryPolicy
+	onRetry      func(RetryEvent)
+	// retries is shared with sessions derived by WithToken.
+	retries     *atomic.Int64
+	pageSize    int
+	impersonate Impersonation
+}
+
+// NewSession accepts either the Rancher base URL (https://rancher.example.com)
+// or the legacy inventory collection URL.
+func NewSession(rawURL, token string, opts SessionOptions) (*Session, error) {
+	base, inventory, err := ParseRancherURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := newTransport(opts)
+	if err != nil {
+		return nil, err
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Session{
+		baseURL:      base,
+		inventoryURL: inventory,
+		token:        token,
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+		},
+		retry:       opts.Retry.normalized(),
+		onRetry:     opts.OnRetry,
+		retries:     new(atomic.Int64),
+		pageSize:    opts.PageSize,
+		impersonate: opts.Impersonate,
+	}, nil
+}
+
+// ParseRancherURL splits a Rancher URL into the server base URL and the
+// inventory collection URL. A legacy inventory URL keeps its path and query.
+func ParseRancherURL(rawURL string) (*url.URL, *url.URL, error) {
+	if rawURL == "" {
+		return nil, nil, fmt.Errorf("rancher URL is required")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid rancher URL: %w", err)
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, nil, fmt.Errorf("invalid rancher URL: %s", rawURL)
+	}
+
+	base := *parsed
+	base.Path = strings.TrimSuffix(stripAPISuffix(parsed.Path), "/")
+	base.RawPath = ""
+	base.RawQuery = ""
+	base.Fragment = ""
+
+	inventory := base.JoinPath(DefaultInventoryPath)
+	if strings.Contains(strings.ToLower(parsed.Path), "machineinventor") {
+		legacy := *parsed
+		legacy.Fragment = ""
+		inventory = &legacy
+	}
+	return &base, inventory, nil
+}
+
+// RetryCount reports how many requests were retried during the session.
+func (s *Session) RetryCount() int64 {
+	return s.retries.Load()
+}
+
+func (s *Session) BaseURL() string {
+	return s.baseURL.String()
+}
+
+// ResultCacheName names a cached list result for this server and credential.
+func (s *Session) ResultCacheName(kind string, parts ...string) string {
+	key := append([]string{s.baseURL.String(), s.inventoryURL.String(), s.token}, s.impersonate.cacheKey()...)
+	return ResultCacheName(append(append(key, kind), parts...)...)
+}
+
+func (s *Session) Inventories() *Client {
+	return s.client(s.inventoryURL)
+}
+
+func (s *Session) Machines() *Client {
+	return s.client(s.baseURL.JoinPath(DefaultMachinesPath))
+}
+
+func (s *Session) Clusters() *Client {
+	return s.client(s.baseURL.JoinPath(DefaultClustersPath))
+}
+
+func (s *Session) GenerateKubeconfig(ctx context.Context, clusterID string) ([]byte, error) {
+	return s.Clusters().GenerateKubeconfig(ctx, clusterID)
+}
+
+func newTransport(opts SessionOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+
+	if opts.CAFile != "" || len(opts.CAData) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if opts.CAFile != "" {
+			data, err := os.ReadFile(opts.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read rancher CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("no certificates found in rancher CA file %s", opts.CAFile)
+			}
+		}
+		if len(opts.CAData) > 0 && !pool.AppendCertsFromPEM(opts.CAData) {
+			return nil, fmt.Errorf("invalid rancher CA data")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if opts.CertFile != "" || opts.KeyFile != "" {
+		if opts.CertFile == "" || opts.KeyFile == "" {
+			return nil, fmt.Errorf("rancher client certificate and key must be provided together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rancher client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if opts.ProxyURL != "" {
+		proxy, err := url.Parse(opts.ProxyURL)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid rancher proxy URL: %s", opts.ProxyURL)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	} else {
+		transport.Proxy = http.ProxyFromEnvironment
+	}
+	return transport, nil
+}
+
+func (s *Session) client(target *url.URL) *Client {
+	return s.clientWithToken(target, s.token)
+}
+
+func (s *Session) clientWithToken(target *url.URL, token string) *Client {
+	return &Client{
+		baseURL:     target,
+		token:       token,
+		httpClient:  s.httpClient,
+		retry:       s.retry,
+		onRetry:     s.onRetry,
+		retries:     s.retries,
+		pageSize:    s.pageSize,
+		impersonate: s.impersonationFor(target),
+	}
+}
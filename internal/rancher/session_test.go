@@ -0,0 +1,67 @@
+package rancher
+
+import "testing"
+
+func TestParseRancherURLBase(t *testing.T) {
+	base, inventory, err := ParseRancherURL("https://rancher.example.com/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if base.String() != "https://rancher.example.com" {
+		t.Fatalf("expected base https://rancher.example.com, got %s", base)
+	}
+	if inventory.String() != "https://rancher.example.com"+DefaultInventoryPath {
+		t.Fatalf("unexpected inventory URL %s", inventory)
+	}
+}
+
+func TestParseRancherURLLegacyInventory(t *testing.T) {
+	raw := "https://rancher.example.com/rancher/v1/elemental.cattle.io.machineinventories?limit=50"
+	base, inventory, err := ParseRancherURL(raw)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if base.String() != "https://rancher.example.com/rancher" {
+		t.Fatalf("expected subpath base, got %s", base)
+	}
+	if inventory.String() != raw {
+		t.Fatalf("expected legacy inventory URL to be kept, got %s", inventory)
+	}
+}
+
+func TestSessionAccessorsShareTransport(t *testing.T) {
+	session, err := NewSession("https://rancher.example.com/v1/elemental.cattle.io.machineinventories", "token", SessionOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	machines := session.Machines()
+	clusters := session.Clusters()
+	if machines.baseURL.String() != "https://rancher.example.com"+DefaultMachinesPath {
+		t.Fatalf("unexpected machines URL %s", machines.baseURL)
+	}
+	if clusters.baseURL.String() != "https://rancher.example.com"+DefaultClustersPath {
+		t.Fatalf("unexpected clusters URL %s", clusters.baseURL)
+	}
+	if machines.httpClient != clusters.httpClient || session.Inventories().httpClient != machines.httpClient {
+		t.Fatalf("expected accessors to share one HTTP client")
+	}
+}
+
+func TestNewSessionProxyAndCertValidation(t *testing.T) {
+	if _, err := NewSession("https://rancher.example.com", "token", SessionOptions{ProxyURL: "://bad"}); err == nil {
+		t.Fatalf("expected invalid proxy error")
+	}
+	if _, err := NewSession("https://rancher.example.com", "token", SessionOptions{CertFile: "client.crt"}); err == nil {
+		t.Fatalf("expected error for certificate without key")
+	}
+	if _, err := NewSession("https://rancher.example.com", "token", SessionOptions{CAData: []byte("not a cert")}); err == nil {
+		t.Fatalf("expected error for invalid CA data")
+	}
+	session, err := NewSession("https://rancher.example.com", "token", SessionOptions{ProxyURL: "http://proxy.example.com:3128"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if session.httpClient.Transport == nil {
+		t.Fatalf("expected transport to be configured")
+	}
+}
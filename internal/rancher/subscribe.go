@@ -0,0 +1,321 @@
+package rancher
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/types"
+	"golang.org/x/net/websocket"
+)
+
+const SubscribePath = "/v1/subscribe"
+
+// Steve drops idle subscriptions without pinging more often than this.
+const subscribeReadTimeout = 90 * time.Second
+
+type ResourceEventType string
+
+const (
+	ResourceCreated ResourceEventType = "resource.create"
+	ResourceChanged ResourceEventType = "resource.change"
+	ResourceRemoved ResourceEventType = "resource.remove"
+)
+
+// ResourceEvent is a create, change or remove notification from Steve.
+type ResourceEvent struct {
+	Type            ResourceEventType
+	ResourceType    string
+	ResourceVersion string
+	Object          map[string]any
+}
+
+func (e ResourceEvent) Host() types.InventoryHost {
+	return normalizeHost(e.Object)
+}
+
+func (e ResourceEvent) Machine() Machine {
+	return normalizeMachine(e.Object)
+}
+
+type SubscribeOptions struct {
+	// ResourceTypes are Steve schema IDs, which are singular; they default
+	// to MachineInventories and CAPI Machines.
+	ResourceTypes []string
+	// MaxFailures is how many consecutive connection attempts may fail
+	// before the subscription gives up; zero uses the retry policy attempts.
+	MaxFailures int
+	// OnReconnect is called before each reconnect attempt.
+	OnReconnect func(attempt int, wait time.Duration, err error)
+}
+
+// Subscription streams resource events until its context is done or it
+// gives up reconnecting.
+type Subscription struct {
+	events chan ResourceEvent
+	err    error
+}
+
+// Events is closed when the subscription ends; Err then reports why.
+func (s *Subscription) Events() <-chan ResourceEvent {
+	return s.events
+}
+
+// Err returns nil after a cancelled context, or the last connection error.
+// It is only meaningful once Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+type subscribeMessage struct {
+	Name            string          `json:"name"`
+	ResourceType    string          `json:"resourceType"`
+	ResourceVersion string          `json:"resourceVersion,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+type subscribeRequest struct {
+	ResourceType    string `json:"resourceType"`
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// Subscribe opens Steve's /v1/subscribe websocket and watches the requested
+// resource types. After a dropped connection or a stopped watch it
+// resubscribes from the last resource version seen, so no event is lost;
+// if Steve reports that version as too old the watch restarts from now.
+func (s *Session) Subscribe(ctx context.Context, opts SubscribeOptions) *Subscription {
+	resourceTypes := opts.ResourceTypes
+	if len(resourceTypes) == 0 {
+		resourceTypes = []string{InventorySchemaID, MachineSchemaID}
+	}
+	maxFailures := opts.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = s.retry.Attempts
+	}
+	sub := &Subscription{events: make(chan ResourceEvent)}
+	versions := map[string]string{}
+
+	go func() {
+		defer close(sub.events)
+		failures := 0
+		for {
+			received, err := s.runSubscription(ctx, resourceTypes, versions, sub.events)
+			if ctx.Err() != nil {
+				return
+			}
+			if received {
+				failures = 0
+			}
+			failures++
+			if failures > maxFailures {
+				sub.err = err
+				return
+			}
+			wait := s.retry.delay(failures-1, err)
+			if opts.OnReconnect != nil {
+				opts.OnReconnect(failures, wait, err)
+			}
+			if sleepContext(ctx, wait) != nil {
+				return
+			}
+		}
+	}()
+	return sub
+}
+
+// runSubscription serves one websocket connection until it fails. received
+// reports whether any message arrived, which resets the failure count.
+func (s *Session) runSubscription(ctx context.Context, resourceTypes []string, versions map[string]string, out chan<- ResourceEvent) (bool, error) {
+	endpoint := s.baseURL.JoinPath(SubscribePath)
+	conn, err := s.dialWebsocket(ctx, endpoint)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	subscribe := func(resourceType string) error {
+		req := subscribeRequest{ResourceType: resourceType, ResourceVersion: versions[resourceType]}
+		if err := websocket.JSON.Send(conn, req); err != nil {
+			return &APIError{Kind: ErrUnreachable, Endpoint: endpoint.String(), Message: "subscribe failed", Err: err}
+		}
+		return nil
+	}
+	for _, resourceType := range resourceTypes {
+		if err := subscribe(resourceType); err != nil {
+			return false, err
+		}
+	}
+
+	received := false
+	for {
+		if err := conn.SetReadDeadline(time.Now().Add(subscribeReadTimeout)); err != nil {
+			return received, err
+		}
+		var msg subscribeMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			return received, &APIError{Kind: ErrUnreachable, Endpoint: endpoint.String(), Message: "subscription closed", Err: err}
+		}
+		received = true
+
+		switch ResourceEventType(msg.Name) {
+		case ResourceCreated, ResourceChanged, ResourceRemoved:
+			var object map[string]any
+			if err := json.Unmarshal(msg.Data, &object); err != nil {
+				continue
+			}
+			event := ResourceEvent{
+				Type:            ResourceEventType(msg.Name),
+				ResourceType:    msg.ResourceType,
+				ResourceVersion: firstString(object, "metadata.resourceVersion"),
+				Object:          object,
+			}
+			if event.ResourceVersion != "" {
+				versions[msg.ResourceType] = event.ResourceVersion
+			}
+			select {
+			case out <- event:
+			case <-ctx.Done():
+				return received, ctx.Err()
+			}
+		case "resource.stop":
+			// Steve ends watches periodically; pick up where this one stopped.
+			if err := subscribe(msg.ResourceType); err != nil {
+				return received, err
+			}
+		case "resource.error":
+			// The usual cause is a resource version that is too old to resume from.
+			delete(versions, msg.ResourceType)
+		}
+	}
+}
+
+// dialWebsocket connects through the session transport's proxy and TLS
+// settings and authenticates with the session token.
+func (s *Session) dialWebsocket(ctx context.Context, endpoint *url.URL) (*websocket.Conn, error) {
+	location := *endpoint
+	switch location.Scheme {
+	case "https":
+		location.Scheme = "wss"
+	case "http":
+		location.Scheme = "ws"
+	}
+	config, err := websocket.NewConfig(location.String(), s.baseURL.String())
+	if err != nil {
+		return nil, &APIError{Kind: ErrBadURL, Endpoint: endpoint.String(), Err: err}
+	}
+	config.Header = http.Header{"Authorization": {"Bearer " + s.token}}
+	s.impersonationFor(endpoint).apply(config.Header)
+
+	transport, _ := s.httpClient.Transport.(*http.Transport)
+	var tlsConfig *tls.Config
+	if transport != nil && transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	} else {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = endpoint.Hostname()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return nil, &APIError{Kind: ErrBadURL, Endpoint: endpoint.String(), Err: err}
+	}
+	raw, err := dialTunnel(ctx, transport, endpoint)
+	if err != nil {
+		return nil, transportError(req, err)
+	}
+	if location.Scheme == "wss" {
+		tlsConn := tls.Client(raw, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, transportError(req, err)
+		}
+		raw = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		raw.SetDeadline(deadline)
+	} else {
+		raw.SetDeadline(time.Now().Add(defaultTimeout))
+	}
+	conn, err := websocket.NewClient(config, raw)
+	if err != nil {
+		raw.Close()
+		if errors.Is(err, websocket.ErrBadStatus) {
+			return nil, &APIError{Kind: ErrUnknown, Endpoint: endpoint.String(), Message: "websocket upgrade rejected; check the token and that the Steve API is enabled", Err: err}
+		}
+		return nil, &APIError{Kind: ErrUnreachable, Endpoint: endpoint.String(), Err: err}
+	}
+	raw.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// dialTunnel opens a TCP connection to endpoint, through an HTTP CONNECT
+// proxy when the transport selects one.
+func dialTunnel(ctx context.Context, transport *http.Transport, endpoint *url.URL) (net.Conn, error) {
+	address := endpoint.Host
+	if endpoint.Port() == "" {
+		port := "443"
+		if endpoint.Scheme == "http" {
+			port = "80"
+		}
+		address = net.JoinHostPort(endpoint.Hostname(), port)
+	}
+	var proxy *url.URL
+	if transport != nil && transport.Proxy != nil {
+		var err error
+		proxy, err = transport.Proxy(&http.Request{URL: endpoint})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var dialer net.Dialer
+	if proxy == nil {
+		return dialer.DialContext(ctx, "tcp", address)
+	}
+
+	proxyAddress := proxy.Host
+	if proxy.Port() == "" {
+		proxyAddress = net.JoinHostPort(proxy.Hostname(), "80")
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", proxyAddress)
+	if err != nil {
+		return nil, err
+	}
+	connect := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if user := proxy.User; user != nil {
+		password, _ := user.Password()
+		connect.SetBasicAuth(user.Username(), password)
+		connect.Header.Set("Proxy-Authorization", connect.Header.Get("Authorization"))
+		connect.Header.Del("Authorization")
+	}
+	if err := connect.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), connect)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT to %s failed: %s", address, resp.Status)
+	}
+	return conn, nil
+}
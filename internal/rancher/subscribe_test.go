@@ -0,0 +1,136 @@
+package rancher
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestSubscribeStreamsAndResumes(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		connections int
+		resumedFrom []string
+	)
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		if got := conn.Request().Header.Get("Authorization"); got != "Bearer token" {
+			t.Errorf("unexpected authorization header %q", got)
+			return
+		}
+		var req subscribeRequest
+		if err := websocket.JSON.Receive(conn, &req); err != nil {
+			t.Errorf("receive subscribe request: %v", err)
+			return
+		}
+		// Steve subscribes by schema ID, not by the plural collection name.
+		if req.ResourceType != "elemental.cattle.io.machineinventory" {
+			t.Errorf("unexpected resource type %q", req.ResourceType)
+		}
+		mu.Lock()
+		connections++
+		first := connections == 1
+		resumedFrom = append(resumedFrom, req.ResourceVersion)
+		mu.Unlock()
+
+		if first {
+			websocket.JSON.Send(conn, map[string]any{"name": "ping"})
+			websocket.JSON.Send(conn, map[string]any{
+				"name":         "resource.create",
+				"resourceType": "elemental.cattle.io.machineinventory",
+				"data": map[string]any{
+					"id":       "fleet-default/m-1",
+					"metadata": map[string]any{"name": "m-1", "namespace": "fleet-default", "resourceVersion": "41"},
+				},
+			})
+			websocket.JSON.Send(conn, map[string]any{
+				"name":         "resource.change",
+				"resourceType": "elemental.cattle.io.machineinventory",
+				"data": map[string]any{
+					"id":       "fleet-default/m-1",
+					"metadata": map[string]any{"name": "m-1", "namespace": "fleet-default", "resourceVersion": "42"},
+				},
+			})
+			// Dropping the connection forces a reconnect.
+			return
+		}
+		websocket.JSON.Send(conn, map[string]any{
+			"name":         "resource.remove",
+			"resourceType": "elemental.cattle.io.machineinventory",
+			"data": map[string]any{
+				"id":       "fleet-default/m-1",
+				"metadata": map[string]any{"name": "m-1", "namespace": "fleet-default", "resourceVersion": "43"},
+			},
+		})
+		var ignored subscribeRequest
+		websocket.JSON.Receive(conn, &ignored)
+	}))
+	defer server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{Retry: RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reconnects := 0
+	sub := session.Subscribe(ctx, SubscribeOptions{
+		ResourceTypes: []string{InventorySchemaID},
+		OnReconnect:   func(int, time.Duration, error) { reconnects++ },
+	})
+
+	var got []ResourceEvent
+	for event := range sub.Events() {
+		got = append(got, event)
+		if len(got) == 3 {
+			cancel()
+		}
+	}
+	if err := sub.Err(); err != nil {
+		t.Fatalf("expected nil error after cancel, got %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(got))
+	}
+	want := []ResourceEventType{ResourceCreated, ResourceChanged, ResourceRemoved}
+	for i, event := range got {
+		if event.Type != want[i] {
+			t.Fatalf("event %d: expected %s, got %s", i, want[i], event.Type)
+		}
+	}
+	if got[0].ResourceType != InventorySchemaID {
+		t.Fatalf("expected events for %s, got %q", InventorySchemaID, got[0].ResourceType)
+	}
+	if host := got[0].Host(); host.ID != "fleet-default/m-1" {
+		t.Fatalf("expected normalized host id fleet-default/m-1, got %q", host.ID)
+	}
+	if reconnects != 1 {
+		t.Fatalf("expected 1 reconnect, got %d", reconnects)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(resumedFrom) != 2 || resumedFrom[0] != "" || resumedFrom[1] != "42" {
+		t.Fatalf("expected resubscribe from resource version 42, got %v", resumedFrom)
+	}
+}
+
+func TestSubscribeGivesUp(t *testing.T) {
+	server := httptest.NewServer(nil)
+	server.Close()
+
+	session, err := NewSession(server.URL, "token", SessionOptions{Retry: RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sub := session.Subscribe(context.Background(), SubscribeOptions{MaxFailures: 2})
+	for range sub.Events() {
+		t.Fatalf("expected no events")
+	}
+	if err := sub.Err(); err == nil {
+		t.Fatalf("expected an error after repeated connection failures")
+	}
+}
@@ -0,0 +1,128 @@
+package rancher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// tokenCommandSkew drops cached command tokens slightly before they expire.
+const tokenCommandSkew = time.Minute
+
+type commandToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+func ReadTokenFile(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read rancher token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("rancher token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// TokenFromCommand runs a credential helper that prints
+// {"token": "...", "expiresAt": "RFC3339"} on stdout. Tokens with an expiry
+// are cached until shortly before they expire; RANCHER_URL is passed to the
+// helper so one helper can serve several servers.
+func TokenFromCommand(ctx context.Context, command, rancherURL string) (string, error) {
+	if token, ok := CachedCommandToken(command, rancherURL); ok {
+		return token, nil
+	}
+	key := command + "|" + rancherURL
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "RANCHER_URL="+rancherURL)
+	cmd.Stderr = os.Stderr
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("rancher token command failed: %w", err)
+	}
+
+	token, err := parseCommandToken(stdout.Bytes())
+	if err != nil {
+		return "", err
+	}
+	if !token.ExpiresAt.IsZero() {
+		// Caching is best effort; the token is still usable without it.
+		_ = saveCommandToken(key, token)
+	}
+	return token.Token, nil
+}
+
+// CachedCommandToken returns the unexpired token TokenFromCommand cached for
+// command, without running it.
+func CachedCommandToken(command, rancherURL string) (string, bool) {
+	cached, ok := loadCommandToken(command+"|"+rancherURL, time.Now())
+	return cached.Token, ok
+}
+
+func parseCommandToken(data []byte) (commandToken, error) {
+	var token commandToken
+	if err := json.Unmarshal(bytes.TrimSpace(data), &token); err != nil {
+		return commandToken{}, fmt.Errorf("rancher token command returned invalid JSON: %w", err)
+	}
+	token.Token = strings.TrimSpace(token.Token)
+	if token.Token == "" {
+		return commandToken{}, fmt.Errorf("rancher token command returned no token")
+	}
+	return token, nil
+}
+
+func loadCommandToken(key string, now time.Time) (commandToken, bool) {
+	cache, err := OpenCache()
+	if err != nil {
+		return commandToken{}, false
+	}
+	data, ok, err := cache.Get(commandTokenCacheName(key))
+	if err != nil || !ok {
+		return commandToken{}, false
+	}
+	var token commandToken
+	if err := json.Unmarshal(data, &token); err != nil || token.Token == "" {
+		return commandToken{}, false
+	}
+	if token.ExpiresAt.IsZero() || !now.Add(tokenCommandSkew).Before(token.ExpiresAt) {
+		return commandToken{}, false
+	}
+	return token, true
+}
+
+func saveCommandToken(key string, token commandToken) error {
+	cache, err := OpenCache()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return cache.Put(commandTokenCacheName(key), data)
+}
+
+func commandTokenCacheName(key string) string {
+	return CacheName("token-command-", ".json", key)
+}
@@ -0,0 +1,66 @@
+package rancher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReadTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("token-abc:secret\n"), 0600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	token, err := ReadTokenFile(path)
+	if err != nil || token != "token-abc:secret" {
+		t.Fatalf("unexpected token %q err=%v", token, err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatalf("write token: %v", err)
+	}
+	if _, err := ReadTokenFile(empty); err == nil {
+		t.Fatalf("expected error for empty token file")
+	}
+}
+
+func TestTokenFromCommandCachesUntilExpiry(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	counter := filepath.Join(t.TempDir(), "calls")
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	command := `echo call >> ` + counter + `; echo '{"token":"token-cmd:'"$RANCHER_URL"'","expiresAt":"` + expires + `"}'`
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		token, err := TokenFromCommand(ctx, command, "https://rancher.example.com")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if token != "token-cmd:https://rancher.example.com" {
+			t.Fatalf("unexpected token %q", token)
+		}
+	}
+	calls, err := os.ReadFile(counter)
+	if err != nil {
+		t.Fatalf("read counter: %v", err)
+	}
+	if string(calls) != "call\n" {
+		t.Fatalf("expected the command to run once, got %q", string(calls))
+	}
+}
+
+func TestTokenFromCommandInvalidOutput(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	ctx := context.Background()
+	if _, err := TokenFromCommand(ctx, `echo not-json`, "https://rancher.example.com"); err == nil {
+		t.Fatalf("expected invalid JSON error")
+	}
+	if _, err := TokenFromCommand(ctx, `echo '{"token":""}'`, "https://rancher.example.com"); err == nil {
+		t.Fatalf("expected missing token error")
+	}
+	if _, err := TokenFromCommand(ctx, `exit 3`, "https://rancher.example.com"); err == nil {
+		t.Fatalf("expected command failure")
+	}
+}
@@ -0,0 +1,106 @@
+package rancher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/goldyfruit/elemental-node-mapper/internal/fileutil"
+)
+
+// StoredToken is an API token minted by `login` and kept in the user config dir.
+type StoredToken struct {
+	RancherURL string    `json:"rancherURL"`
+	Name       string    `json:"name,omitempty"`
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expiresAt,omitzero"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+func (t StoredToken) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+func (t StoredToken) ExpiresWithin(d time.Duration, now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && t.ExpiresAt.Sub(now) <= d
+}
+
+func LoadStoredToken(rancherURL string) (StoredToken, bool, error) {
+	key, err := tokenStoreKey(rancherURL)
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	path, err := tokenStorePath()
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	var tokens map[string]StoredToken
+	err = fileutil.WithDirLock(filepath.Dir(path), false, func() error {
+		tokens, err = readTokenStore(path)
+		return err
+	})
+	if err != nil {
+		return StoredToken{}, false, err
+	}
+	token, ok := tokens[key]
+	return token, ok, nil
+}
+
+// SaveStoredToken merges token into the store under an exclusive lock, so
+// concurrent logins to different servers keep each other's tokens.
+func SaveStoredToken(token StoredToken) error {
+	key, err := tokenStoreKey(token.RancherURL)
+	if err != nil {
+		return err
+	}
+	path, err := tokenStorePath()
+	if err != nil {
+		return err
+	}
+	token.RancherURL = key
+	return fileutil.WithDirLock(filepath.Dir(path), true, func() error {
+		tokens, err := readTokenStore(path)
+		if err != nil {
+			return err
+		}
+		tokens[key] = token
+		data, err := json.MarshalIndent(tokens, "", "  ")
+		if err != nil {
+			return err
+		}
+		return fileutil.WriteFileAtomic(path, data)
+	})
+}
+
+func readTokenStore(path string) (map[string]StoredToken, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]StoredToken{}, nil
+		}
+		return nil, err
+	}
+	tokens := map[string]StoredToken{}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token store %s: %w", path, err)
+	}
+	return tokens, nil
+}
+
+func tokenStoreKey(rancherURL string) (string, error) {
+	base, _, err := ParseRancherURL(rancherURL)
+	if err != nil {
+		return "", err
+	}
+	return base.String(), nil
+}
+
+func tokenStorePath() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config dir: %w", err)
+	}
+	return filepath.Join(base, "elemental-node-map", "tokens.json"), nil
+}
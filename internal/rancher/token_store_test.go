@@ -0,0 +1,85 @@
+package rancher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoredTokenRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	expires := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	err := SaveStoredToken(StoredToken{
+		RancherURL: "https://rancher.example.com/v1/elemental.cattle.io.machineinventories",
+		Name:       "token-abc",
+		Token:      "token-abc:secret",
+		ExpiresAt:  expires,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "elemental-node-map", "tokens.json"))
+	if err != nil {
+		t.Fatalf("expected token store file: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("expected 0600 permissions, got %o", info.Mode().Perm())
+	}
+
+	stored, ok, err := LoadStoredToken("https://rancher.example.com/")
+	if err != nil || !ok {
+		t.Fatalf("expected stored token, ok=%v err=%v", ok, err)
+	}
+	if stored.Token != "token-abc:secret" || !stored.ExpiresAt.Equal(expires) {
+		t.Fatalf("unexpected stored token %+v", stored)
+	}
+	if _, ok, _ := LoadStoredToken("https://other.example.com"); ok {
+		t.Fatalf("expected no token for another server")
+	}
+}
+
+func TestStoredTokenExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := StoredToken{ExpiresAt: now.Add(time.Hour)}
+	if token.Expired(now) {
+		t.Fatalf("token should not be expired yet")
+	}
+	if !token.ExpiresWithin(24*time.Hour, now) {
+		t.Fatalf("expected token to expire within a day")
+	}
+	if !token.Expired(now.Add(time.Hour)) {
+		t.Fatalf("expected token to be expired")
+	}
+	if (StoredToken{}).Expired(now) || (StoredToken{}).ExpiresWithin(time.Hour, now) {
+		t.Fatalf("tokens without expiry never expire")
+	}
+}
+
+func TestStoredTokenConcurrentSaves(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			url := fmt.Sprintf("https://rancher-%d.example.com", i)
+			if err := SaveStoredToken(StoredToken{RancherURL: url, Token: fmt.Sprintf("token-%d:secret", i)}); err != nil {
+				t.Errorf("save: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		if _, ok, err := LoadStoredToken(fmt.Sprintf("https://rancher-%d.example.com", i)); err != nil || !ok {
+			t.Fatalf("expected token %d to survive concurrent saves, ok=%v err=%v", i, ok, err)
+		}
+	}
+}
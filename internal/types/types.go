@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 // K8sNode is a normalized view of a Kubernetes node.
 type K8sNode struct {
 	Name        string
@@ -11,6 +13,37 @@ type K8sNode struct {
 	InternalIPs []string
 	ExternalIPs []string
 	Annotations map[string]string
+	Cluster     string
+
+	Ready         bool
+	Unschedulable bool
+	Roles         []string
+	Conditions    []NodeCondition
+	Taints        []NodeTaint
+
+	KubeletVersion          string
+	OSImage                 string
+	KernelVersion           string
+	ContainerRuntimeVersion string
+
+	CreatedAt   time.Time
+	Capacity    map[string]string
+	Allocatable map[string]string
+}
+
+// NodeCondition is a node status condition such as Ready or DiskPressure.
+type NodeCondition struct {
+	Type               string
+	Status             string
+	Reason             string
+	Message            string
+	LastTransitionTime time.Time
+}
+
+type NodeTaint struct {
+	Key    string
+	Value  string
+	Effect string
 }
 
 // InventoryHost is a normalized view of a Rancher Elemental inventory host.